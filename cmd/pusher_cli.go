@@ -1,38 +1,115 @@
 /*
 Package main is a command line executable that push InfluxDB line protocol file to InfluxDB.
+The validate command (pusher validate -f data.txt) checks line protocol files
+offline.
 */
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	pusher "github.com/barasher/influxdb-pusher/pkg"
+	"github.com/barasher/influxdb-pusher/pkg/convert"
+	"github.com/barasher/influxdb-pusher/pkg/lineprotocol"
 	"github.com/sirupsen/logrus"
 )
 
 const (
-	retOk          int = 0
-	retConfFailure int = 1
-	retExecFailure int = 2
+	retOk             int = 0
+	retConfFailure    int = 1
+	retExecFailure    int = 2
+	retPartialFailure int = 3
+	retInvalidData    int = 4
 )
 
+const stdinFile = "-"
+
+// stdin is the reader used when data is read from standard input
+var stdin io.Reader = os.Stdin
+
+// stdout is the writer where the validation results are written
+var stdout io.Writer = os.Stdout
+
+// isStdinPiped returns true if standard input is not a terminal
+var isStdinPiped = func() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice == 0
+}
+
 func main() {
 	os.Exit(doMain(os.Args[1:]))
 }
 
 func doMain(args []string) int {
+	if len(args) > 0 && args[0] == "validate" {
+		return doValidate(args[1:])
+	}
 	cmd := flag.NewFlagSet("Pusher", flag.ContinueOnError)
 	cons := cmd.String("c", "", "Consistency (any|all|one|quorum)")
 	user := cmd.String("us", "", "Username")
 	pass := cmd.String("p", "", "Password")
+	queryAuth := cmd.Bool("query-auth", false, "Send username and password as query parameters instead of HTTP Basic auth")
 	prec := cmd.String("pr", "", "Precision (ns|u|ms|s|m|h)")
 	retPol := cmd.String("r", "", "Retention policy")
 	url := cmd.String("u", "", "URL, required (sample: http://1.2.3.4:8086)")
-	db := cmd.String("d", "", "Database, required")
-	data := cmd.String("f", "", "File to push, required")
+	db := cmd.String("d", "", "Database, required for InfluxDB 1.x")
+	org := cmd.String("o", "", "Organization, required for InfluxDB 2.x")
+	bucket := cmd.String("b", "", "Bucket, required for InfluxDB 2.x")
+	token := cmd.String("tk", "", "Token")
+	caFile := cmd.String("ca", "", "PEM file of the certificate authorities trusted in addition to the system ones")
+	certFile := cmd.String("cert", "", "PEM file of the client certificate (mutual TLS)")
+	keyFile := cmd.String("key", "", "PEM file of the client certificate key (mutual TLS)")
+	insecure := cmd.Bool("insecure", false, "Don't verify the certificate of the server")
+	var data stringList
+	cmd.Var(&data, "f", "File, glob or directory to push, can be repeated, required ('-' for standard input, default if piped)")
+	recursive := cmd.Bool("R", false, "Push directories recursively")
 	timeout := cmd.String("t", "", "Timeout duration (50s, 120ms, 1m, ...)")
+	batchSize := cmd.Int("bs", 0, "Maximum number of points per write request (0: no limit)")
+	batchBytes := cmd.Int("bb", 0, "Maximum size in bytes of a write request (0: no limit, 10 MiB if -bs is 0 too)")
+	gz := cmd.Bool("gzip", false, "Compress write requests with gzip")
+	deadLetter := cmd.String("dead-letter", "", "File where rejected lines are written")
+	checkpoint := cmd.String("checkpoint", "", "State file where the progress of the pushed files is saved")
+	resume := cmd.Bool("resume", false, "Resume the pushed files from the checkpoint file")
+	dryRun := cmd.Bool("dry-run", false, "Read and split the data into batches without sending anything")
+	format := cmd.String("format", formatLineProtocol, "Format of the data (lp|csv|annotated-csv|json|prometheus|openmetrics|graphite)")
+	csvSpec := csvFlags{
+		measurement:       cmd.String("csv-measurement", "", "Measurement of the points (csv format)"),
+		measurementColumn: cmd.String("csv-measurement-column", "", "Column holding the measurement (csv format)"),
+		tags:              cmd.String("csv-tags", "", "Comma-separated columns holding tags (csv format)"),
+		fields:            cmd.String("csv-fields", "", "Comma-separated columns holding fields, with an optional type (col:float|integer|unsigned|string|boolean), all the other columns if omitted (csv format)"),
+		time:              cmd.String("csv-time", "", "Column holding the timestamp (csv format)"),
+		timeFormat:        cmd.String("csv-time-format", "", "Format of the timestamps (unix|unix_ms|unix_us|unix_ns|Go time layout, default unix) (csv format)"),
+		separator:         cmd.String("csv-separator", ",", "Field delimiter (csv format)"),
+	}
+	jsonSpec := jsonFlags{
+		measurement:     cmd.String("json-measurement", "", "Measurement of the points (json format)"),
+		measurementPath: cmd.String("json-measurement-path", "", "Path of the measurement (json format)"),
+		tags:            cmd.String("json-tags", "", "Comma-separated paths of the tags (json format)"),
+		fields:          cmd.String("json-fields", "", "Comma-separated paths of the fields, with an optional type (path:float|integer|unsigned|string|boolean), all the other values if omitted (json format)"),
+		time:            cmd.String("json-time", "", "Path of the timestamp (json format)"),
+		timeFormat:      cmd.String("json-time-format", "", "Format of the timestamps (unix|unix_ms|unix_us|unix_ns|Go time layout, default unix) (json format)"),
+		separator:       cmd.String("json-separator", "_", "Separator of the flattened keys (json format)"),
+		explode:         cmd.String("json-explode", "", "Path of an array exploded into multiple points (json format)"),
+	}
+	promMeasurement := cmd.String("prometheus-measurement", "", "Measurement of all the metrics, each metric being a measurement if omitted (prometheus and openmetrics formats)")
+	graphiteSpec := graphiteFlags{separator: cmd.String("graphite-separator", ".", "Separator joining the parts of the measurements and fields (graphite format)")}
+	cmd.Var(&graphiteSpec.templates, "graphite-template", "Template splitting the paths into measurement, tags and field ('[filter] template [tag=value,...]', 'host.measurement.field*' for instance), can be repeated, 'measurement*' if omitted (graphite format)")
+	workers := cmd.Int("w", 1, "Number of write requests sent in parallel")
+	retries := cmd.Int("retries", 1, "Maximum number of attempts per write request")
+	retryBackoff := cmd.String("retry-backoff", "1s", "Delay before the first retry, doubled after each retry")
+	retryMaxBackoff := cmd.String("retry-max-backoff", "30s", "Maximum delay between two attempts")
+	retryMaxWait := cmd.String("retry-max-wait", "", "Maximum delay advertised by InfluxDB (Retry-After) that is waited before retrying, the write request failing if it is longer (default: no limit)")
+	retryJitter := cmd.Float64("retry-jitter", 0.2, "Randomization factor applied to retry delays (0-1)")
 
 	err := cmd.Parse(args)
 	if err != nil {
@@ -46,17 +123,40 @@ func doMain(args []string) int {
 		logrus.Errorf("No URL provided")
 		return retConfFailure
 	}
-	if *db == "" {
-		logrus.Errorf("No database provided")
+	if *db == "" && *bucket == "" {
+		logrus.Errorf("No database or bucket provided")
 		return retConfFailure
 	}
-	if *data == "" {
-		logrus.Errorf("No data file provided")
+	data = append(data, cmd.Args()...)
+	if len(data) == 0 {
+		if !isStdinPiped() {
+			logrus.Errorf("No data file provided")
+			return retConfFailure
+		}
+		data = stringList{stdinFile}
+	}
+	useStdin := len(data) == 1 && data[0] == stdinFile
+	if *resume && *checkpoint == "" {
+		logrus.Errorf("No checkpoint file provided to resume from")
 		return retConfFailure
 	}
+	if useStdin && *checkpoint != "" {
+		logrus.Errorf("Standard input can't be checkpointed")
+		return retConfFailure
+	}
+	var files []string
+	if !useStdin {
+		if files, err = expandFiles(data, *recursive); err != nil {
+			logrus.Errorf("%v", err)
+			return retConfFailure
+		}
+	}
 
 	opts := []func(*pusher.Pusher) error{}
 	opts = append(opts, pusher.OptWithUserPass(*user, *pass))
+	if *queryAuth {
+		opts = append(opts, pusher.OptWithQueryParamCredentials())
+	}
 	if cons, found := getConsistency(*cons); found {
 		opts = append(opts, pusher.OptWithConsistency(cons))
 	}
@@ -66,6 +166,77 @@ func doMain(args []string) int {
 	if *retPol != "" {
 		opts = append(opts, pusher.OptWithRetentionPolicy(*retPol))
 	}
+	if *org != "" {
+		opts = append(opts, pusher.OptWithOrg(*org))
+	}
+	if *bucket != "" {
+		opts = append(opts, pusher.OptWithBucket(*bucket))
+	}
+	if *token != "" {
+		opts = append(opts, pusher.OptWithToken(*token))
+	}
+	if *caFile != "" || *certFile != "" || *keyFile != "" || *insecure {
+		tlsConfig, err := pusher.NewTLSConfig(*caFile, *certFile, *keyFile, *insecure)
+		if err != nil {
+			logrus.Errorf("Error when loading TLS configuration: %v", err)
+			return retConfFailure
+		}
+		opts = append(opts, pusher.OptWithTLSConfig(tlsConfig))
+	}
+	if *batchSize != 0 {
+		opts = append(opts, pusher.OptWithBatchSize(*batchSize))
+	}
+	if *batchBytes != 0 {
+		opts = append(opts, pusher.OptWithBatchBytes(*batchBytes))
+	}
+	if *gz {
+		opts = append(opts, pusher.OptWithGzip())
+	}
+	if *deadLetter != "" {
+		opts = append(opts, pusher.OptWithDeadLetterFile(*deadLetter))
+	}
+	if *checkpoint != "" {
+		opts = append(opts, pusher.OptWithCheckpointFile(*checkpoint))
+	}
+	if *resume {
+		opts = append(opts, pusher.OptWithResume())
+	}
+	if *dryRun {
+		opts = append(opts, pusher.OptWithDryRun())
+	}
+	unit := time.Nanosecond
+	if prec, found := getPrecision(*prec); found {
+		unit = precisionUnits[prec]
+	}
+	conv, err := getConverter(*format, unit, csvSpec, jsonSpec, *promMeasurement, graphiteSpec)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return retConfFailure
+	}
+	if conv != nil {
+		opts = append(opts, pusher.OptWithConverter(conv))
+	}
+	if *workers != 1 {
+		opts = append(opts, pusher.OptWithConcurrency(*workers))
+	}
+	if *retries > 1 {
+		rp := pusher.RetryPolicy{MaxAttempts: *retries, Jitter: *retryJitter}
+		if rp.InitialBackoff, err = time.ParseDuration(*retryBackoff); err != nil {
+			logrus.Errorf("error while parsing duration '%v': %v", *retryBackoff, err)
+			return retConfFailure
+		}
+		if rp.MaxBackoff, err = time.ParseDuration(*retryMaxBackoff); err != nil {
+			logrus.Errorf("error while parsing duration '%v': %v", *retryMaxBackoff, err)
+			return retConfFailure
+		}
+		if *retryMaxWait != "" {
+			if rp.MaxRetryAfter, err = time.ParseDuration(*retryMaxWait); err != nil {
+				logrus.Errorf("error while parsing duration '%v': %v", *retryMaxWait, err)
+				return retConfFailure
+			}
+		}
+		opts = append(opts, pusher.OptWithRetryPolicy(rp))
+	}
 	if *timeout != "" {
 		td, err := time.ParseDuration(*timeout)
 		if err != nil {
@@ -80,15 +251,412 @@ func doMain(args []string) int {
 		logrus.Errorf("Error when initializing pusher: %v", err)
 		return retExecFailure
 	}
-	err = p.Push(*data)
-	if err != nil {
+	if useStdin {
+		rep, err := p.PushReader(context.Background(), stdin)
+		logReport(stdinFile, rep, err)
+		if *dryRun {
+			logDryRun(stdinFile, rep)
+		}
+		return exitCode([]error{err})
+	}
+
+	reps, err := p.PushFiles(context.Background(), files)
+	errs := make([]error, 0, len(files))
+	for _, r := range reps {
+		logReport(r.File, r.Report, r.Err)
+		if *dryRun {
+			logDryRun(r.File, r.Report)
+		}
+		errs = append(errs, r.Err)
+	}
+	if len(reps) < len(files) {
 		logrus.Errorf("Error when pushing data: %v", err)
 		return retExecFailure
 	}
+	return exitCode(errs)
+}
 
+func doValidate(args []string) int {
+	cmd := flag.NewFlagSet("Pusher validate", flag.ContinueOnError)
+	prec := cmd.String("pr", "", "Precision of the timestamps (ns|u|ms|s|m|h, default ns)")
+	var data stringList
+	cmd.Var(&data, "f", "File, glob or directory to validate, can be repeated, required ('-' for standard input, default if piped)")
+	recursive := cmd.Bool("R", false, "Validate directories recursively")
+	jsonOutput := cmd.Bool("json", false, "Write the results as JSON")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		if err != flag.ErrHelp {
+			logrus.Errorf("error while parsing command line arguments: %v", err)
+		}
+		return retConfFailure
+	}
+
+	unit := time.Nanosecond
+	if *prec != "" {
+		p, found := getPrecision(*prec)
+		if !found {
+			logrus.Errorf("Unknown precision '%v'", *prec)
+			return retConfFailure
+		}
+		unit = precisionUnits[p]
+	}
+	data = append(data, cmd.Args()...)
+	if len(data) == 0 {
+		if !isStdinPiped() {
+			logrus.Errorf("No data file provided")
+			return retConfFailure
+		}
+		data = stringList{stdinFile}
+	}
+	files := []string{stdinFile}
+	if len(data) != 1 || data[0] != stdinFile {
+		if files, err = expandFiles(data, *recursive); err != nil {
+			logrus.Errorf("%v", err)
+			return retConfFailure
+		}
+	}
+
+	results := make([]fileValidation, 0, len(files))
+	invalid := false
+	for _, f := range files {
+		rep, err := validateFile(f, unit)
+		if err != nil {
+			logrus.Errorf("Error when validating '%v': %v", f, err)
+			return retExecFailure
+		}
+		invalid = invalid || len(rep.Problems) > 0
+		results = append(results, fileValidation{File: f, ValidationReport: rep})
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			logrus.Errorf("Error when writing results: %v", err)
+			return retExecFailure
+		}
+	} else {
+		for _, r := range results {
+			for _, pb := range r.Problems {
+				fmt.Fprintf(stdout, "%v:%v\n", r.File, pb)
+			}
+			fmt.Fprintf(stdout, "%v: %v line(s), %v point(s), %v problem(s)\n", r.File, r.Lines, r.Points, len(r.Problems))
+		}
+	}
+	if invalid {
+		return retInvalidData
+	}
 	return retOk
 }
 
+// fileValidation is the validation result of a file
+type fileValidation struct {
+	File string `json:"file"`
+	lineprotocol.ValidationReport
+}
+
+func validateFile(f string, unit time.Duration) (lineprotocol.ValidationReport, error) {
+	var in io.Reader = stdin
+	if f != stdinFile {
+		file, err := os.Open(f)
+		if err != nil {
+			return lineprotocol.ValidationReport{}, err
+		}
+		defer file.Close()
+		in = file
+	}
+	r, err := pusher.Decompress(f, in)
+	if err != nil {
+		return lineprotocol.ValidationReport{}, err
+	}
+	defer r.Close()
+	return lineprotocol.Validate(r, unit)
+}
+
+// precisionUnits maps the precisions to the unit of the timestamps
+var precisionUnits = map[pusher.Precision]time.Duration{
+	pusher.PrecisionNanosecond:  time.Nanosecond,
+	pusher.PrecisionMicrosecond: time.Microsecond,
+	pusher.PrecisionMillisecond: time.Millisecond,
+	pusher.PrecisionSecond:      time.Second,
+	pusher.PrecisionMinute:      time.Minute,
+	pusher.PrecisionHour:        time.Hour,
+}
+
+// Formats of the pushed data
+const (
+	formatLineProtocol = "lp"
+	formatCSV          = "csv"
+	formatAnnotatedCSV = "annotated-csv"
+	formatJSON         = "json"
+	formatPrometheus   = "prometheus"
+	formatOpenMetrics  = "openmetrics"
+	formatGraphite     = "graphite"
+)
+
+// csvFlags are the flags describing the mapping of the csv format
+type csvFlags struct {
+	measurement       *string
+	measurementColumn *string
+	tags              *string
+	fields            *string
+	time              *string
+	timeFormat        *string
+	separator         *string
+}
+
+// getConverter returns the converter of the format (nil for line protocol),
+// timestamps being written in unit
+func getConverter(format string, unit time.Duration, csvSpec csvFlags, jsonSpec jsonFlags, promMeasurement string, graphiteSpec graphiteFlags) (pusher.Converter, error) {
+	switch format {
+	case formatLineProtocol:
+		return nil, nil
+	case formatCSV:
+		return csvSpec.converter(unit)
+	case formatAnnotatedCSV:
+		return convert.AnnotatedCSV{Precision: unit}, nil
+	case formatJSON:
+		return jsonSpec.converter(unit)
+	case formatPrometheus, formatOpenMetrics:
+		return convert.Prometheus{Measurement: promMeasurement, OpenMetrics: format == formatOpenMetrics, Precision: unit}, nil
+	case formatGraphite:
+		return graphiteSpec.converter(unit)
+	default:
+		return nil, fmt.Errorf("unknown format '%v'", format)
+	}
+}
+
+func (f csvFlags) converter(unit time.Duration) (pusher.Converter, error) {
+	if *f.measurement == "" && *f.measurementColumn == "" {
+		return nil, fmt.Errorf("no CSV measurement or measurement column provided")
+	}
+	comma := []rune(*f.separator)
+	if len(comma) != 1 {
+		return nil, fmt.Errorf("CSV separator '%v' isn't a single character", *f.separator)
+	}
+	c := convert.CSV{
+		Measurement:       *f.measurement,
+		MeasurementColumn: *f.measurementColumn,
+		Tags:              splitList(*f.tags),
+		TimeColumn:        *f.time,
+		TimeFormat:        *f.timeFormat,
+		Precision:         unit,
+		Comma:             comma[0],
+	}
+	for _, field := range splitList(*f.fields) {
+		col, typ, err := splitTypedField(field)
+		if err != nil {
+			return nil, err
+		}
+		c.Fields = append(c.Fields, convert.CSVField{Column: col, Type: typ})
+	}
+	return c, nil
+}
+
+// jsonFlags are the flags describing the mapping of the json format
+type jsonFlags struct {
+	measurement     *string
+	measurementPath *string
+	tags            *string
+	fields          *string
+	time            *string
+	timeFormat      *string
+	separator       *string
+	explode         *string
+}
+
+func (f jsonFlags) converter(unit time.Duration) (pusher.Converter, error) {
+	if *f.measurement == "" && *f.measurementPath == "" {
+		return nil, fmt.Errorf("no JSON measurement or measurement path provided")
+	}
+	c := convert.JSON{
+		Measurement:     *f.measurement,
+		MeasurementPath: *f.measurementPath,
+		Tags:            splitList(*f.tags),
+		TimePath:        *f.time,
+		TimeFormat:      *f.timeFormat,
+		Precision:       unit,
+		Separator:       *f.separator,
+		Explode:         *f.explode,
+	}
+	for _, field := range splitList(*f.fields) {
+		path, typ, err := splitTypedField(field)
+		if err != nil {
+			return nil, err
+		}
+		c.Fields = append(c.Fields, convert.JSONField{Path: path, Type: typ})
+	}
+	return c, nil
+}
+
+// graphiteFlags are the flags describing the mapping of the graphite format
+type graphiteFlags struct {
+	templates stringList
+	separator *string
+}
+
+func (f graphiteFlags) converter(unit time.Duration) (pusher.Converter, error) {
+	c := convert.Graphite{Templates: f.templates, Separator: *f.separator, Precision: unit}
+	if err := c.CheckTemplates(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// splitTypedField splits a field given as name[:type]
+func splitTypedField(field string) (string, string, error) {
+	i := strings.LastIndex(field, ":")
+	if i < 0 {
+		return field, "", nil
+	}
+	name, typ := field[:i], field[i+1:]
+	if !isFieldType(typ) {
+		return "", "", fmt.Errorf("unknown type '%v' of field '%v'", typ, name)
+	}
+	return name, typ, nil
+}
+
+// splitList splits a comma-separated list, empty if l is empty
+func splitList(l string) []string {
+	if l == "" {
+		return nil
+	}
+	return strings.Split(l, ",")
+}
+
+func isFieldType(t string) bool {
+	for _, name := range lineprotocol.FieldTypeToString {
+		if name == t {
+			return true
+		}
+	}
+	return false
+}
+
+// exitCode computes the exit code from the errors of the pushed files:
+// partial failure if some data has been written despite errors
+func exitCode(errs []error) int {
+	ok, partial, failed := 0, 0, 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			ok++
+		case pusher.IsPartialWriteError(err):
+			partial++
+		default:
+			failed++
+		}
+	}
+	switch {
+	case partial == 0 && failed == 0:
+		return retOk
+	case ok == 0 && partial == 0:
+		return retExecFailure
+	default:
+		logrus.Errorf("%v file(s) out of %v failed, %v partially written", failed, len(errs), partial)
+		return retPartialFailure
+	}
+}
+
+func logReport(f string, rep pusher.Report, err error) {
+	logrus.Infof("%v: %v point(s) pushed in %v batch(es), %v dropped, %v dead lettered, %v retry(ies)", f, rep.Points, rep.Batches, rep.Dropped, rep.DeadLettered, rep.Retries)
+	for _, bf := range rep.Failures {
+		logrus.Errorf("%v: batch #%v (%v point(s)) failed: %v", f, bf.Batch, bf.Points, bf.Err)
+		var pwe *pusher.PartialWriteError
+		if errors.As(bf.Err, &pwe) {
+			for _, rl := range pwe.Rejected {
+				logrus.Errorf("%v: line %v rejected: %v", f, rl.Line, rl.Reason)
+			}
+		}
+	}
+	if err != nil {
+		logrus.Errorf("%v: error when pushing data: %v", f, err)
+	}
+}
+
+func logDryRun(f string, rep pusher.Report) {
+	timeRange := "no timestamp"
+	if !rep.MinTime.IsZero() {
+		timeRange = fmt.Sprintf("from %v to %v", rep.MinTime.Format(time.RFC3339Nano), rep.MaxTime.Format(time.RFC3339Nano))
+	}
+	logrus.Infof("%v: dry run, %v batch(es), %v point(s) and %v byte(s) would be sent, %v", f, rep.Batches, rep.Points, rep.Bytes, timeRange)
+}
+
+// stringList is a flag that can be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// expandFiles expands globs and directories (recursively if recursive is
+// true) into the sorted list of files to push
+func expandFiles(patterns []string, recursive bool) ([]string, error) {
+	files := []string{}
+	seen := map[string]bool{}
+	add := func(f string) {
+		if !seen[f] {
+			seen[f] = true
+			files = append(files, f)
+		}
+	}
+	for _, pattern := range patterns {
+		if pattern == stdinFile {
+			return nil, fmt.Errorf("standard input can't be pushed along with other files")
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error while expanding '%v': %v", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no file matching '%v'", pattern)
+		}
+		for _, m := range matches {
+			fi, err := os.Stat(m)
+			if err != nil {
+				return nil, fmt.Errorf("error while reading '%v': %v", m, err)
+			}
+			if !fi.IsDir() {
+				add(m)
+				continue
+			}
+			dirFiles, err := listDir(m, recursive)
+			if err != nil {
+				return nil, fmt.Errorf("error while listing directory '%v': %v", m, err)
+			}
+			for _, f := range dirFiles {
+				add(f)
+			}
+		}
+	}
+	return files, nil
+}
+
+func listDir(dir string, recursive bool) ([]string, error) {
+	files := []string{}
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
 func getPrecision(p string) (pusher.Precision, bool) {
 	for curP, curS := range pusher.PrecisionToString {
 		if curS == p {
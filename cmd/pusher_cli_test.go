@@ -1,8 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	pusher "github.com/barasher/influxdb-pusher/pkg"
@@ -20,6 +30,601 @@ func TestDoMainNominal(t *testing.T) {
 	assert.Equal(t, retOk, ret)
 }
 
+func TestDoMainV2(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/api/v2/write", req.URL.Path)
+		assert.Equal(t, "o", req.URL.Query().Get("org"))
+		assert.Equal(t, "b", req.URL.Query().Get("bucket"))
+		assert.Equal(t, "Token tok", req.Header.Get("Authorization"))
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ret := doMain([]string{"-u", srv.URL, "-o", "o", "-b", "b", "-tk", "tok", "-f", "../testdata/sampleData.txt"})
+	assert.Equal(t, retOk, ret)
+}
+
+func TestDoMainBatches(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ret := doMain([]string{"-u", srv.URL, "-d", "db", "-bs", "1000", "-f", "../testdata/sampleData.txt"})
+	assert.Equal(t, retOk, ret)
+	assert.Equal(t, 2, requests)
+}
+
+func TestDoMainWorkers(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ret := doMain([]string{"-u", srv.URL, "-d", "db", "-bs", "100", "-w", "4", "-f", "../testdata/sampleData.txt"})
+	assert.Equal(t, retOk, ret)
+	assert.Equal(t, int32(20), atomic.LoadInt32(&requests))
+}
+
+func TestDoMainRetries(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ret := doMain([]string{"-u", srv.URL, "-d", "db", "-retries", "3", "-retry-backoff", "1ms", "-f", "../testdata/sampleData.txt"})
+	assert.Equal(t, retOk, ret)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestDoMainGzip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ret := doMain([]string{"-u", srv.URL, "-d", "db", "-gzip", "-f", "../testdata/sampleData.txt"})
+	assert.Equal(t, retOk, ret)
+}
+
+func TestDoMainStdin(t *testing.T) {
+	defer func(r io.Reader, f func() bool) {
+		stdin = r
+		isStdinPiped = f
+	}(stdin, isStdinPiped)
+
+	var tcs = []struct {
+		tcID    string
+		inArgs  []string
+		inPiped bool
+		expCode int
+		expData string
+	}{
+		{"dash", []string{"-f", "-"}, false, retOk, "a v=1\n"},
+		{"piped", []string{}, true, retOk, "a v=1\n"},
+		{"notPiped", []string{}, false, retConfFailure, ""},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			var received []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				c, err := ioutil.ReadAll(req.Body)
+				assert.Nil(t, err)
+				received = append(received, c...)
+				rw.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			stdin = strings.NewReader("a v=1\n")
+			isStdinPiped = func() bool { return tc.inPiped }
+			ret := doMain(append([]string{"-u", srv.URL, "-d", "db"}, tc.inArgs...))
+			assert.Equal(t, tc.expCode, ret)
+			assert.Equal(t, tc.expData, string(received))
+		})
+	}
+}
+
+func TestDoMainMultipleFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "ok1.txt"), []byte("a v=1\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "sub", "ok2.txt"), []byte("a v=2\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "sub", "ko.txt"), []byte("ko v=3\n"), 0644))
+
+	var tcs = []struct {
+		tcID        string
+		inArgs      []string
+		expCode     int
+		expRequests int32
+	}{
+		{"severalFlags", []string{"-f", filepath.Join(dir, "ok1.txt"), "-f", filepath.Join(dir, "sub", "ok2.txt")}, retOk, 2},
+		{"positionalArgs", []string{filepath.Join(dir, "ok1.txt"), filepath.Join(dir, "sub", "ok2.txt")}, retOk, 2},
+		{"glob", []string{"-f", filepath.Join(dir, "*", "ok*.txt")}, retOk, 1},
+		{"directory", []string{"-f", dir}, retOk, 1},
+		{"recursive", []string{"-R", "-f", dir}, retPartialFailure, 3},
+		{"allFailed", []string{"-f", filepath.Join(dir, "sub", "ko.txt")}, retExecFailure, 1},
+		{"noMatch", []string{"-f", filepath.Join(dir, "*.csv")}, retConfFailure, 0},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			var requests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				c, err := ioutil.ReadAll(req.Body)
+				assert.Nil(t, err)
+				if strings.HasPrefix(string(c), "ko") {
+					rw.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				rw.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			ret := doMain(append([]string{"-u", srv.URL, "-d", "db"}, tc.inArgs...))
+			assert.Equal(t, tc.expCode, ret)
+			assert.Equal(t, tc.expRequests, atomic.LoadInt32(&requests))
+		})
+	}
+}
+
+func TestDoMainPartialWrite(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(`{"error":"partial write: points beyond retention policy dropped=12"}`))
+	}))
+	defer srv.Close()
+
+	ret := doMain([]string{"-u", srv.URL, "-d", "db", "-f", "../testdata/sampleData.txt"})
+	assert.Equal(t, retPartialFailure, ret)
+}
+
+func TestDoMainDeadLetter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	dl := filepath.Join(dir, "dl.txt")
+	data := filepath.Join(dir, "data.txt")
+	assert.Nil(t, ioutil.WriteFile(data, []byte("m v=1\nm bad\nm v=3\n"), 0644))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		c, err := ioutil.ReadAll(req.Body)
+		assert.Nil(t, err)
+		if strings.Contains(string(c), "bad") {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ret := doMain([]string{"-u", srv.URL, "-d", "db", "-dead-letter", dl, "-f", data})
+	assert.Equal(t, retOk, ret)
+	c, err := ioutil.ReadFile(dl)
+	assert.Nil(t, err)
+	assert.Contains(t, string(c), data+":2")
+	assert.Contains(t, string(c), "\nm bad\n")
+}
+
+func TestDoMainResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	state := filepath.Join(dir, "state.json")
+
+	failing := true
+	points := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		c, err := ioutil.ReadAll(req.Body)
+		assert.Nil(t, err)
+		if failing && points == 1000 {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		points += strings.Count(string(c), "\n")
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	params := []string{"-u", srv.URL, "-d", "db", "-bs", "500", "-checkpoint", state, "-f", "../testdata/sampleData.txt"}
+	assert.Equal(t, retExecFailure, doMain(params))
+	assert.Equal(t, 1000, points)
+	_, err = os.Stat(state)
+	assert.Nil(t, err)
+
+	failing = false
+	assert.Equal(t, retOk, doMain(append(params, "-resume")))
+	assert.Equal(t, 2000, points)
+	_, err = os.Stat(state)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDoMainCredentials(t *testing.T) {
+	var tcs = []struct {
+		tcID         string
+		inArgs       []string
+		expBasicAuth bool
+		expQueryUser string
+	}{
+		{"basicAuth", []string{}, true, ""},
+		{"queryAuth", []string{"-query-auth"}, false, "user"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				user, _, ok := req.BasicAuth()
+				assert.Equal(t, tc.expBasicAuth, ok)
+				if ok {
+					assert.Equal(t, "user", user)
+				}
+				assert.Equal(t, tc.expQueryUser, req.URL.Query().Get("u"))
+				rw.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			args := append([]string{"-u", srv.URL, "-d", "db", "-us", "user", "-p", "secret", "-f", "../testdata/sampleData.txt"}, tc.inArgs...)
+			assert.Equal(t, retOk, doMain(args))
+		})
+	}
+}
+
+func TestDoMainTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	ca := filepath.Join(dir, "ca.pem")
+	assert.Nil(t, ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0644))
+
+	var tcs = []struct {
+		tcID    string
+		inArgs  []string
+		expCode int
+	}{
+		{"unknownAuthority", []string{}, retExecFailure},
+		{"ca", []string{"-ca", ca}, retOk},
+		{"insecure", []string{"-insecure"}, retOk},
+		{"missingCA", []string{"-ca", filepath.Join(dir, "missing.pem")}, retConfFailure},
+		{"certWithoutKey", []string{"-cert", ca}, retConfFailure},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			args := append([]string{"-u", srv.URL, "-d", "db", "-f", "../testdata/sampleData.txt"}, tc.inArgs...)
+			assert.Equal(t, tc.expCode, doMain(args))
+		})
+	}
+}
+
+func TestDoMainDryRun(t *testing.T) {
+	requests := int32(0)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ret := doMain([]string{"-u", srv.URL, "-d", "db", "-us", "user", "-p", "secret", "-pr", "s", "-bs", "500", "-dry-run", "-f", "../testdata/sampleData.txt"})
+	assert.Equal(t, retOk, ret)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+}
+
+func TestDoMainCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	data := filepath.Join(dir, "data.csv")
+	assert.Nil(t, ioutil.WriteFile(data, []byte("time;host;region;value\n10;h1;eu;1.5\n20;h2;us;2\n"), 0644))
+
+	var tcs = []struct {
+		tcID    string
+		inArgs  []string
+		expCode int
+		expData string
+	}{
+		{"nominal", []string{"-csv-measurement", "cpu", "-csv-tags", "host,region", "-csv-time", "time"}, retOk, "cpu,host=h1,region=eu value=1.5 10000000000\ncpu,host=h2,region=us value=2 20000000000\n"},
+		{"typedFields", []string{"-csv-measurement", "cpu", "-csv-fields", "value:float,region", "-csv-time", "time", "-pr", "ms"}, retOk, "cpu value=1.5,region=\"eu\" 10000\ncpu value=2,region=\"us\" 20000\n"},
+		{"measurementColumn", []string{"-csv-measurement-column", "host", "-csv-fields", "value"}, retOk, "h1 value=1.5\nh2 value=2\n"},
+		{"invalidValue", []string{"-csv-measurement", "cpu", "-csv-fields", "value:integer"}, retExecFailure, ""},
+		{"noMeasurement", []string{"-csv-tags", "host"}, retConfFailure, ""},
+		{"unknownType", []string{"-csv-measurement", "cpu", "-csv-fields", "value:double"}, retConfFailure, ""},
+		{"invalidSeparator", []string{"-csv-measurement", "cpu", "-csv-separator", ";;"}, retConfFailure, ""},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			received := ""
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				c, err := ioutil.ReadAll(req.Body)
+				assert.Nil(t, err)
+				received += string(c)
+				rw.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			args := append([]string{"-u", srv.URL, "-d", "db", "-format", "csv", "-csv-separator", ";", "-f", data}, tc.inArgs...)
+			assert.Equal(t, tc.expCode, doMain(args))
+			assert.Equal(t, tc.expData, received)
+		})
+	}
+
+	assert.Equal(t, retConfFailure, doMain([]string{"-u", "http://localhost", "-d", "db", "-format", "xml", "-f", data}))
+}
+
+func TestDoMainAnnotatedCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	data := filepath.Join(dir, "export.csv")
+	export := "#group,false,false,true,true,false,false,true,true,true\n" +
+		"#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,double,string,string,string\n" +
+		"#default,_result,,,,,,,,\n" +
+		",result,table,_start,_stop,_time,_value,_field,_measurement,host\n" +
+		",,0,2020-01-01T00:00:00Z,2020-01-02T00:00:00Z,2020-01-01T00:00:10Z,0.5,usage,cpu,a\n"
+	assert.Nil(t, ioutil.WriteFile(data, []byte(export), 0644))
+
+	received := ""
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		c, err := ioutil.ReadAll(req.Body)
+		assert.Nil(t, err)
+		received += string(c)
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ret := doMain([]string{"-u", srv.URL, "-o", "o", "-b", "b", "-pr", "s", "-format", "annotated-csv", "-f", data})
+	assert.Equal(t, retOk, ret)
+	assert.Equal(t, "cpu,host=a usage=0.5 1577836810\n", received)
+}
+
+func TestDoMainJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	data := filepath.Join(dir, "app.log")
+	logs := "{\"ts\":\"2020-01-01T00:00:10Z\",\"host\":{\"name\":\"a\"},\"req\":{\"status\":200,\"ms\":12.5}}\n" +
+		"{\"ts\":\"2020-01-01T00:00:20Z\",\"host\":{\"name\":\"b\"},\"req\":{\"status\":500,\"ms\":3}}\n"
+	assert.Nil(t, ioutil.WriteFile(data, []byte(logs), 0644))
+
+	var tcs = []struct {
+		tcID    string
+		inArgs  []string
+		expCode int
+		expData string
+	}{
+		{"inferredFields", []string{"-json-measurement", "http", "-json-tags", "host.name", "-json-time", "ts", "-json-time-format", "2006-01-02T15:04:05Z07:00"}, retOk, "http,host_name=a req_ms=12.5,req_status=200 1577836810\nhttp,host_name=b req_ms=3,req_status=500 1577836820\n"},
+		{"typedFields", []string{"-json-measurement", "http", "-json-fields", "req.status:integer", "-json-separator", "."}, retOk, "http req.status=200i\nhttp req.status=500i\n"},
+		{"noMeasurement", []string{"-json-tags", "host.name"}, retConfFailure, ""},
+		{"unknownType", []string{"-json-measurement", "http", "-json-fields", "req.status:int"}, retConfFailure, ""},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			received := ""
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				c, err := ioutil.ReadAll(req.Body)
+				assert.Nil(t, err)
+				received += string(c)
+				rw.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			args := append([]string{"-u", srv.URL, "-d", "db", "-pr", "s", "-format", "json", "-f", data}, tc.inArgs...)
+			assert.Equal(t, tc.expCode, doMain(args))
+			assert.Equal(t, tc.expData, received)
+		})
+	}
+}
+
+func TestDoMainPrometheus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	promData := filepath.Join(dir, "metrics.txt")
+	assert.Nil(t, ioutil.WriteFile(promData, []byte("# TYPE http_requests counter\nhttp_requests{code=\"200\"} 1027 1395066363000\n"), 0644))
+	omData := filepath.Join(dir, "metrics.om")
+	assert.Nil(t, ioutil.WriteFile(omData, []byte("# TYPE http_requests counter\nhttp_requests_total{code=\"200\"} 1027 1395066363\n# EOF\n"), 0644))
+
+	var tcs = []struct {
+		tcID    string
+		inArgs  []string
+		expCode int
+		expData string
+	}{
+		{"perMetric", []string{"-format", "prometheus", "-f", promData}, retOk, "http_requests,code=200 counter=1027 1395066363\n"},
+		{"singleMeasurement", []string{"-format", "prometheus", "-prometheus-measurement", "prometheus", "-f", promData}, retOk, "prometheus,code=200 http_requests=1027 1395066363\n"},
+		{"openMetrics", []string{"-format", "openmetrics", "-f", omData}, retOk, "http_requests,code=200 counter=1027 1395066363\n"},
+		{"openMetricsTimestampsInMs", []string{"-format", "openmetrics", "-f", promData}, retExecFailure, ""},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			received := ""
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				c, err := ioutil.ReadAll(req.Body)
+				assert.Nil(t, err)
+				received += string(c)
+				rw.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			args := append([]string{"-u", srv.URL, "-d", "db", "-pr", "s"}, tc.inArgs...)
+			assert.Equal(t, tc.expCode, doMain(args))
+			assert.Equal(t, tc.expData, received)
+		})
+	}
+}
+
+func TestDoMainGraphite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	data := filepath.Join(dir, "graphite.txt")
+	assert.Nil(t, ioutil.WriteFile(data, []byte("host1.cpu.load.1m 0.5 1439856000\nstats.gauges.mem 3 1439856000\n"), 0644))
+
+	var tcs = []struct {
+		tcID    string
+		inArgs  []string
+		expCode int
+		expData string
+	}{
+		{"defaultTemplate", []string{}, retOk, "host1.cpu.load.1m value=0.5 1439856000\nstats.gauges.mem value=3 1439856000\n"},
+		{"templates", []string{"-graphite-template", "stats.* ..measurement env=prod", "-graphite-template", "host.measurement.field*", "-graphite-separator", "_"}, retOk, "cpu,host=host1 load_1m=0.5 1439856000\nmem,env=prod value=3 1439856000\n"},
+		{"invalidTemplate", []string{"-graphite-template", "host.field"}, retConfFailure, ""},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			received := ""
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				c, err := ioutil.ReadAll(req.Body)
+				assert.Nil(t, err)
+				received += string(c)
+				rw.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			args := append([]string{"-u", srv.URL, "-d", "db", "-pr", "s", "-format", "graphite", "-f", data}, tc.inArgs...)
+			assert.Equal(t, tc.expCode, doMain(args))
+			assert.Equal(t, tc.expData, received)
+		})
+	}
+}
+
+func TestDoValidate(t *testing.T) {
+	defer func(r io.Reader, w io.Writer, f func() bool) {
+		stdin = r
+		stdout = w
+		isStdinPiped = f
+	}(stdin, stdout, isStdinPiped)
+	isStdinPiped = func() bool { return false }
+
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	invalid := filepath.Join(dir, "invalid.txt")
+	assert.Nil(t, ioutil.WriteFile(invalid, []byte("m v=1 10\nm v=\nm v=1i 20\n"), 0644))
+
+	var tcs = []struct {
+		tcID      string
+		inArgs    []string
+		expCode   int
+		expOutput []string
+	}{
+		{"valid", []string{"-pr", "s", "-f", "../testdata/sampleData.txt"}, retOk, []string{"../testdata/sampleData.txt: 2000 line(s), 2000 point(s), 0 problem(s)"}},
+		{"compressed", []string{"-pr", "s", "../testdata/sampleData.txt.zst"}, retOk, []string{"../testdata/sampleData.txt.zst: 2000 line(s), 2000 point(s), 0 problem(s)"}},
+		{"wrongPrecision", []string{"-f", "../testdata/sampleData.txt"}, retInvalidData, []string{
+			"../testdata/sampleData.txt:1: timestamp: timestamp 1439856000 is implausible with precision 'ns'",
+			"2000 problem(s)",
+		}},
+		{"invalid", []string{"-pr", "s", "-f", invalid}, retInvalidData, []string{
+			invalid + ":1: timestamp: timestamp 10 is implausible",
+			invalid + ":2:5: syntax: missing value of field 'v'",
+			invalid + ":3: type conflict: field 'v' of measurement 'm' is integer, it is float at line 1",
+			invalid + ": 3 line(s), 2 point(s), 4 problem(s)",
+		}},
+		{"stdin", []string{"-pr", "s", "-f", "-"}, retOk, []string{"-: 1 line(s), 1 point(s), 0 problem(s)"}},
+		{"unknownPrecision", []string{"-pr", "x", "-f", invalid}, retConfFailure, []string{}},
+		{"noFile", []string{}, retConfFailure, []string{}},
+		{"missingFile", []string{"-f", "missing.txt"}, retConfFailure, []string{}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			var out bytes.Buffer
+			stdout = &out
+			stdin = strings.NewReader("m v=1 1439856000\n")
+			assert.Equal(t, tc.expCode, doMain(append([]string{"validate"}, tc.inArgs...)))
+			for _, o := range tc.expOutput {
+				assert.Contains(t, out.String(), o)
+			}
+		})
+	}
+}
+
+func TestDoValidateJSON(t *testing.T) {
+	defer func(w io.Writer) { stdout = w }(stdout)
+	var out bytes.Buffer
+	stdout = &out
+
+	assert.Equal(t, retInvalidData, doMain([]string{"validate", "-json", "-f", "../testdata/sampleData.txt"}))
+	var results []map[string]interface{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &results))
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "../testdata/sampleData.txt", results[0]["file"])
+		assert.Equal(t, 2000.0, results[0]["points"])
+		problems := results[0]["problems"].([]interface{})
+		assert.Len(t, problems, 2000)
+		assert.Equal(t, "timestamp", problems[0].(map[string]interface{})["kind"])
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	pwe := &pusher.PartialWriteError{}
+	e := fmt.Errorf("e")
+	var tcs = []struct {
+		tcID    string
+		inErrs  []error
+		expCode int
+	}{
+		{"ok", []error{nil, nil}, retOk},
+		{"failed", []error{e, e}, retExecFailure},
+		{"someFailed", []error{nil, e}, retPartialFailure},
+		{"partialWrite", []error{pwe}, retPartialFailure},
+		{"partialWriteAndFailure", []error{pwe, e}, retPartialFailure},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			assert.Equal(t, tc.expCode, exitCode(tc.inErrs))
+		})
+	}
+}
+
+func TestExpandFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "a", "b"), 0755))
+	for _, f := range []string{"1.txt", "2.txt", filepath.Join("a", "3.txt"), filepath.Join("a", "b", "4.txt")} {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, f), []byte{}, 0644))
+	}
+
+	var tcs = []struct {
+		tcID        string
+		inPatterns  []string
+		inRecursive bool
+		expErr      bool
+		expFiles    []string
+	}{
+		{"file", []string{filepath.Join(dir, "1.txt")}, false, false, []string{filepath.Join(dir, "1.txt")}},
+		{"glob", []string{filepath.Join(dir, "*.txt")}, false, false, []string{filepath.Join(dir, "1.txt"), filepath.Join(dir, "2.txt")}},
+		{"directory", []string{dir}, false, false, []string{filepath.Join(dir, "1.txt"), filepath.Join(dir, "2.txt")}},
+		{"recursive", []string{dir}, true, false, []string{filepath.Join(dir, "1.txt"), filepath.Join(dir, "2.txt"), filepath.Join(dir, "a", "3.txt"), filepath.Join(dir, "a", "b", "4.txt")}},
+		{"duplicates", []string{filepath.Join(dir, "1.txt"), filepath.Join(dir, "*.txt")}, false, false, []string{filepath.Join(dir, "1.txt"), filepath.Join(dir, "2.txt")}},
+		{"noMatch", []string{filepath.Join(dir, "*.csv")}, false, true, nil},
+		{"badPattern", []string{"["}, false, true, nil},
+		{"stdinWithFiles", []string{"-", filepath.Join(dir, "1.txt")}, false, true, nil},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			files, err := expandFiles(tc.inPatterns, tc.inRecursive)
+			assert.Equal(t, tc.expErr, err != nil)
+			if !tc.expErr {
+				assert.Equal(t, tc.expFiles, files)
+			}
+		})
+	}
+}
+
 func TestDoMainExecutionFailure(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -31,6 +636,9 @@ func TestDoMainExecutionFailure(t *testing.T) {
 }
 
 func TestDoMainFailure(t *testing.T) {
+	defer func(f func() bool) { isStdinPiped = f }(isStdinPiped)
+	isStdinPiped = func() bool { return false }
+
 	var tcs = []struct {
 		tcID    string
 		params  []string
@@ -39,8 +647,15 @@ func TestDoMainFailure(t *testing.T) {
 		{"help", []string{"-h"}, retConfFailure},
 		{"noUrl", []string{"-d", "a", "-f", "a"}, retConfFailure},
 		{"noDatabase", []string{"-u", "a", "-f", "a"}, retConfFailure},
+		{"noDatabaseNoBucket", []string{"-u", "a", "-o", "o", "-f", "a"}, retConfFailure},
 		{"noFile", []string{"-u", "a", "-d", "a"}, retConfFailure},
 		{"parseError", []string{"-turlututu"}, retConfFailure},
+		{"invalidWorkers", []string{"-u", "url", "-d", "db", "-f", "../testdata/sampleData.txt", "-w", "0"}, retExecFailure},
+		{"unparsableRetryBackoff", []string{"-u", "url", "-d", "db", "-f", "a", "-retries", "2", "-retry-backoff", "bla"}, retConfFailure},
+		{"unparsableRetryMaxBackoff", []string{"-u", "url", "-d", "db", "-f", "a", "-retries", "2", "-retry-max-backoff", "bla"}, retConfFailure},
+		{"unparsableRetryMaxWait", []string{"-u", "url", "-d", "db", "-f", "a", "-retries", "2", "-retry-max-wait", "bla"}, retConfFailure},
+		{"resumeWithoutCheckpoint", []string{"-u", "url", "-d", "db", "-f", "../testdata/sampleData.txt", "-resume"}, retConfFailure},
+		{"checkpointStdin", []string{"-u", "url", "-d", "db", "-f", "-", "-checkpoint", "state.json"}, retConfFailure},
 		{"unparsableTimeout", []string{"-u", "url", "-d", "db", "-f", "a", "-t", "bla"}, retConfFailure},
 	}
 
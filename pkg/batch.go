@@ -0,0 +1,132 @@
+package pusher
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+)
+
+// defaultBatchBytes is the maximum size of the batches when neither their
+// number of points nor their size is limited, so that the data is never
+// loaded in memory as a whole
+const defaultBatchBytes = 10 * 1024 * 1024
+
+// batch is a set of consecutive lines sent in a single write request
+type batch struct {
+	index     int
+	firstLine int
+	data      []byte
+	points    int
+	// file, if not nil, holds the gzip compressed data of the batch, which
+	// is sent untouched. Its points, lines and size are only known once it
+	// has been read as a whole (counted).
+	file    *io.SectionReader
+	counted bool
+	// endLine is the number of the last line of the batch and endOffset the
+	// offset, in the uncompressed data, of the byte following the batch
+	endLine   int
+	endOffset int64
+}
+
+// size returns the size of the data of the batch, before compression
+func (bt *batch) size() int64 {
+	if bt.file != nil {
+		return bt.endOffset
+	}
+	return int64(len(bt.data))
+}
+
+// reader returns a reader providing the uncompressed data of the batch
+func (bt *batch) reader() (io.ReadCloser, error) {
+	if bt.file == nil {
+		return ioutil.NopCloser(bytes.NewReader(bt.data)), nil
+	}
+	return gzip.NewReader(io.NewSectionReader(bt.file, 0, bt.file.Size()))
+}
+
+// tally counts the line l, read from the uncompressed data of the batch
+func (bt *batch) tally(l []byte) {
+	if len(l) > 0 {
+		bt.endLine++
+		bt.endOffset += int64(len(l))
+	}
+	if isPoint(l) {
+		bt.points++
+	}
+}
+
+// batcher splits a line protocol stream into batches, on line boundaries.
+// A batch is closed as soon as adding the next point would exceed maxPoints
+// points or maxBytes bytes (0 means no limit). A single line exceeding
+// maxBytes is sent alone.
+type batcher struct {
+	r         *bufio.Reader
+	maxPoints int
+	maxBytes  int
+	pending   []byte
+	lines     int
+	offset    int64
+}
+
+func newBatcher(r io.Reader, maxPoints int, maxBytes int) *batcher {
+	return &batcher{r: bufio.NewReader(r), maxPoints: maxPoints, maxBytes: maxBytes}
+}
+
+// next returns the next batch, or io.EOF if there is no more point to send
+func (b *batcher) next() (*batch, error) {
+	bt := batch{firstLine: b.lines + 1}
+	for {
+		line := b.pending
+		b.pending = nil
+		if line == nil {
+			l, err := b.r.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			if len(l) == 0 {
+				if bt.points == 0 {
+					return nil, io.EOF
+				}
+				return b.end(&bt), nil
+			}
+			line = l
+		}
+
+		point := isPoint(line)
+		if point && bt.points > 0 && b.isFull(&bt, line) {
+			b.pending = line
+			return b.end(&bt), nil
+		}
+		bt.data = append(bt.data, line...)
+		b.lines++
+		b.offset += int64(len(line))
+		if point {
+			bt.points++
+		}
+	}
+}
+
+// end records where the batch bt ends
+func (b *batcher) end(bt *batch) *batch {
+	bt.endLine = b.lines
+	bt.endOffset = b.offset
+	return bt
+}
+
+func (b *batcher) isFull(bt *batch, line []byte) bool {
+	if b.maxPoints > 0 && bt.points >= b.maxPoints {
+		return true
+	}
+	if b.maxBytes > 0 && len(bt.data)+len(line) > b.maxBytes {
+		return true
+	}
+	return false
+}
+
+// isPoint returns true if the line is neither empty nor a comment
+func isPoint(line []byte) bool {
+	l := bytes.TrimSpace(line)
+	return len(l) > 0 && l[0] != '#'
+}
@@ -0,0 +1,86 @@
+package pusher
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatcher(t *testing.T) {
+	var tcs = []struct {
+		tcID      string
+		inData    string
+		inPoints  int
+		inBytes   int
+		expData   []string
+		expPoints []int
+	}{
+		{"noLimit", "a v=1\nb v=2\n", 0, 0, []string{"a v=1\nb v=2\n"}, []int{2}},
+		{"empty", "", 0, 0, []string{}, []int{}},
+		{"noTrailingNewline", "a v=1\nb v=2", 1, 0, []string{"a v=1\n", "b v=2"}, []int{1, 1}},
+		{"points", "a v=1\nb v=2\nc v=3\n", 2, 0, []string{"a v=1\nb v=2\n", "c v=3\n"}, []int{2, 1}},
+		{"bytes", "a v=1\nb v=2\nc v=3\n", 0, 13, []string{"a v=1\nb v=2\n", "c v=3\n"}, []int{2, 1}},
+		{"lineBiggerThanLimit", "a v=1\nbbbbbbbbbbbbbbbb v=2\nc v=3\n", 0, 8, []string{"a v=1\n", "bbbbbbbbbbbbbbbb v=2\n", "c v=3\n"}, []int{1, 1, 1}},
+		{"commentsAndBlanks", "# c\na v=1\n\nb v=2\n# end\n", 1, 0, []string{"# c\na v=1\n\n", "b v=2\n# end\n"}, []int{1, 1}},
+		{"onlyComments", "# c\n\n", 1, 0, []string{}, []int{}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			b := newBatcher(strings.NewReader(tc.inData), tc.inPoints, tc.inBytes)
+			data := []string{}
+			points := []int{}
+			for {
+				bt, err := b.next()
+				if err == io.EOF {
+					break
+				}
+				assert.Nil(t, err)
+				data = append(data, string(bt.data))
+				points = append(points, bt.points)
+			}
+			assert.Equal(t, tc.expData, data)
+			assert.Equal(t, tc.expPoints, points)
+		})
+	}
+}
+
+func TestBatcherEnds(t *testing.T) {
+	b := newBatcher(strings.NewReader("# c\na v=1\nb v=2\n\nc v=3"), 1, 0)
+	ends := [][2]int64{}
+	for {
+		bt, err := b.next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		ends = append(ends, [2]int64{int64(bt.endLine), bt.endOffset})
+	}
+	assert.Equal(t, [][2]int64{{2, 10}, {4, 17}, {5, 22}}, ends)
+}
+
+func TestPushDefaultBatchBytes(t *testing.T) {
+	var sizes []int
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		c, err := ioutil.ReadAll(req.Body)
+		assert.Nil(t, err)
+		sizes = append(sizes, len(c))
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	line := "m v=1\n"
+	full := defaultBatchBytes / len(line)
+	p, err := NewPusher(srv.URL, "d")
+	assert.Nil(t, err)
+	rep, err := p.PushReader(context.Background(), strings.NewReader(strings.Repeat(line, full+1)))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, rep.Batches)
+	assert.Equal(t, full+1, rep.Points)
+	assert.Equal(t, []int{full * len(line), len(line)}, sizes)
+}
@@ -0,0 +1,211 @@
+package pusher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OptWithCheckpointFile is an optional function that specifies the state
+// file where the progress of the pushed files is saved. Each time a batch
+// is acknowledged by InfluxDB (written, even partially), and every previous
+// batch of the file is too, the checkpoint of the file (offset and line
+// following the batch, number of acknowledged batches, checksum of the
+// file) is saved. The checkpoint is removed once the file has been
+// completely pushed.
+// Data pushed with PushReader is not checkpointed.
+func OptWithCheckpointFile(path string) func(*Pusher) error {
+	return func(p *Pusher) error {
+		if path == "" {
+			return fmt.Errorf("no checkpoint file provided")
+		}
+		p.checkpoints = &checkpointFile{path: path}
+		return nil
+	}
+}
+
+// OptWithResume is an optional function that makes the pushes of files
+// resume from their checkpoint (see OptWithCheckpointFile), if any. The
+// checkpoint is refused, and the push fails, if the file changed since the
+// checkpoint has been saved.
+func OptWithResume() func(*Pusher) error {
+	return func(p *Pusher) error {
+		p.resume = true
+		return nil
+	}
+}
+
+// checkpoint is the progress of the push of a file
+type checkpoint struct {
+	// Checksum is the checksum of the pushed file
+	Checksum string `json:"checksum"`
+	// Offset is the offset, in the uncompressed data, of the first byte
+	// that hasn't been acknowledged
+	Offset int64 `json:"offset"`
+	// Line is the number of the last acknowledged line
+	Line int `json:"line"`
+	// Batches is the number of acknowledged batches
+	Batches int `json:"batches"`
+}
+
+// checkpointFile is the state file holding the checkpoints, by absolute
+// file path
+type checkpointFile struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (c *checkpointFile) load() (map[string]checkpoint, error) {
+	cps := map[string]checkpoint{}
+	content, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return cps, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error when reading checkpoint file '%v': %v", c.path, err)
+	}
+	if err := json.Unmarshal(content, &cps); err != nil {
+		return nil, fmt.Errorf("error when parsing checkpoint file '%v': %v", c.path, err)
+	}
+	return cps, nil
+}
+
+func (c *checkpointFile) get(key string) (checkpoint, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cps, err := c.load()
+	if err != nil {
+		return checkpoint{}, false, err
+	}
+	cp, found := cps[key]
+	return cp, found, nil
+}
+
+// set saves the checkpoint of key, or removes it if cp is nil. The state
+// file is removed when it doesn't hold any checkpoint anymore.
+func (c *checkpointFile) set(key string, cp *checkpoint) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cps, err := c.load()
+	if err != nil {
+		return err
+	}
+	if cp != nil {
+		cps[key] = *cp
+	} else {
+		delete(cps, key)
+	}
+
+	if len(cps) == 0 {
+		if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error when removing checkpoint file '%v': %v", c.path, err)
+		}
+		return nil
+	}
+	content, err := json.MarshalIndent(cps, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error when serializing checkpoints: %v", err)
+	}
+	// written aside then renamed so that an interruption never leaves a
+	// truncated state file
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, 0644); err != nil {
+		return fmt.Errorf("error when writing checkpoint file '%v': %v", tmp, err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("error when writing checkpoint file '%v': %v", c.path, err)
+	}
+	return nil
+}
+
+// progress tracks the acknowledged batches of the push of a file. The
+// checkpoint only moves past a batch once every previous batch has been
+// acknowledged.
+type progress struct {
+	store *checkpointFile
+	key   string
+	cp    checkpoint
+	acked map[int]batchEnd
+}
+
+// batchEnd is where an acknowledged batch ends
+type batchEnd struct {
+	line   int
+	offset int64
+}
+
+// startProgress returns the progress of the push of the file f (named
+// name), starting from its checkpoint if the push is resumed.
+// f is read to compute its checksum and rewound.
+func (p *Pusher) startProgress(name string, f *os.File) (*progress, error) {
+	key, err := filepath.Abs(name)
+	if err != nil {
+		return nil, newError(errTypePusher, fmt.Errorf("error when resolving path of '%v': %v", name, err))
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, newError(errTypePusher, fmt.Errorf("error when computing checksum of '%v': %v", name, err))
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, newError(errTypePusher, fmt.Errorf("error when rewinding '%v': %v", name, err))
+	}
+	pr := progress{
+		store: p.checkpoints,
+		key:   key,
+		cp:    checkpoint{Checksum: "sha256:" + hex.EncodeToString(h.Sum(nil))},
+		acked: map[int]batchEnd{},
+	}
+	if !p.resume {
+		return &pr, nil
+	}
+
+	cp, found, err := p.checkpoints.get(key)
+	if err != nil {
+		return nil, newError(errTypePusher, err)
+	}
+	if !found {
+		return &pr, nil
+	}
+	if cp.Checksum != pr.cp.Checksum {
+		return nil, newError(errTypePusher, fmt.Errorf("checkpoint of '%v' refused: file changed since the checkpoint has been saved", name))
+	}
+	logrus.Infof("Resuming push of '%v' after line %v (%v batch(es) already acknowledged)", name, cp.Line, cp.Batches)
+	pr.cp = cp
+	return &pr, nil
+}
+
+// ack records that the batch bt has been acknowledged (written, even
+// partially) and saves the checkpoint if it moved
+func (pr *progress) ack(bt *batch) error {
+	pr.acked[bt.index] = batchEnd{line: bt.endLine, offset: bt.endOffset}
+	moved := false
+	for {
+		b, found := pr.acked[pr.cp.Batches]
+		if !found {
+			break
+		}
+		delete(pr.acked, pr.cp.Batches)
+		pr.cp.Offset = b.offset
+		pr.cp.Line = b.line
+		pr.cp.Batches++
+		moved = true
+	}
+	if !moved {
+		return nil
+	}
+	cp := pr.cp
+	return pr.store.set(pr.key, &cp)
+}
+
+// done removes the checkpoint once the file has been completely pushed
+func (pr *progress) done() error {
+	return pr.store.set(pr.key, nil)
+}
@@ -0,0 +1,211 @@
+package pusher
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptWithCheckpointFile(t *testing.T) {
+	p := Pusher{}
+	assert.Nil(t, OptWithCheckpointFile("state.json")(&p))
+	assert.Equal(t, "state.json", p.checkpoints.path)
+	assert.NotNil(t, OptWithCheckpointFile("")(&p))
+}
+
+func TestOptWithResume(t *testing.T) {
+	p := Pusher{}
+	assert.Nil(t, OptWithResume()(&p))
+	assert.True(t, p.resume)
+
+	_, err := NewPusher("http://localhost", "d", OptWithResume())
+	assert.NotNil(t, err)
+	_, err = NewPusher("http://localhost", "d", OptWithResume(), OptWithCheckpointFile("state.json"))
+	assert.Nil(t, err)
+}
+
+func TestProgressAck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	store := &checkpointFile{path: filepath.Join(dir, "state.json")}
+	pr := progress{store: store, key: "f", cp: checkpoint{Checksum: "c"}, acked: map[int]batchEnd{}}
+
+	assert.Nil(t, pr.ack(&batch{index: 1, endLine: 20, endOffset: 200}))
+	_, found, err := store.get("f")
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	assert.Nil(t, pr.ack(&batch{index: 0, endLine: 10, endOffset: 100}))
+	cp, found, err := store.get("f")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, checkpoint{Checksum: "c", Offset: 200, Line: 20, Batches: 2}, cp)
+
+	assert.Nil(t, pr.done())
+	_, err = os.Stat(store.path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPushCheckpointResume(t *testing.T) {
+	var tcs = []struct {
+		tcID   string
+		inFile string
+		inOpts []func(*Pusher) error
+	}{
+		{"plain", "../testdata/sampleData.txt", []func(*Pusher) error{}},
+		{"compressed", "../testdata/sampleData.txt.gz", []func(*Pusher) error{}},
+		{"concurrency", "../testdata/sampleData.txt", []func(*Pusher) error{OptWithConcurrency(3)}},
+	}
+	expected, err := ioutil.ReadFile("../testdata/sampleData.txt")
+	assert.Nil(t, err)
+	lines := strings.SplitAfter(string(expected), "\n")
+
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "pusher")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+			state := filepath.Join(dir, "state.json")
+
+			failing := true
+			var mu sync.Mutex
+			received := []string{}
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				c, err := ioutil.ReadAll(req.Body)
+				assert.Nil(t, err)
+				if failing && strings.HasPrefix(string(c), lines[1000]) {
+					rw.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				if !failing {
+					mu.Lock()
+					received = append(received, string(c))
+					mu.Unlock()
+				}
+				rw.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			opts := append([]func(*Pusher) error{OptWithBatchSize(250), OptWithCheckpointFile(state)}, tc.inOpts...)
+			p, err := NewPusher(srv.URL, "d", opts...)
+			assert.Nil(t, err)
+			_, err = p.Push(tc.inFile)
+			assert.True(t, IsServerProblemError(err))
+
+			content, err := ioutil.ReadFile(state)
+			assert.Nil(t, err)
+			cps := map[string]checkpoint{}
+			assert.Nil(t, json.Unmarshal(content, &cps))
+			abs, err := filepath.Abs(tc.inFile)
+			assert.Nil(t, err)
+			assert.Equal(t, 4, cps[abs].Batches)
+			assert.Equal(t, 1000, cps[abs].Line)
+			assert.Equal(t, int64(len(strings.Join(lines[:1000], ""))), cps[abs].Offset)
+			assert.True(t, strings.HasPrefix(cps[abs].Checksum, "sha256:"))
+
+			failing = false
+			p, err = NewPusher(srv.URL, "d", append(opts, OptWithResume())...)
+			assert.Nil(t, err)
+			rep, err := p.Push(tc.inFile)
+			assert.Nil(t, err)
+			assert.Equal(t, 4, rep.Batches)
+			assert.Equal(t, 1000, rep.Points)
+			if tc.tcID != "concurrency" {
+				assert.Equal(t, strings.Join(lines[1000:], ""), strings.Join(received, ""))
+			}
+			_, err = os.Stat(state)
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestPushCheckpointFileChanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	state := filepath.Join(dir, "state.json")
+	data := filepath.Join(dir, "data.txt")
+	assert.Nil(t, ioutil.WriteFile(data, []byte("a v=1\nb v=2\n"), 0644))
+
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		if requests == 2 {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	opts := []func(*Pusher) error{OptWithBatchSize(1), OptWithCheckpointFile(state), OptWithResume()}
+	p, err := NewPusher(srv.URL, "d", opts...)
+	assert.Nil(t, err)
+	_, err = p.Push(data)
+	assert.True(t, IsServerProblemError(err))
+	assert.Equal(t, 2, requests)
+
+	assert.Nil(t, ioutil.WriteFile(data, []byte("a v=1\nb v=3\n"), 0644))
+	_, err = p.Push(data)
+	assert.True(t, IsPusherError(err))
+	assert.Contains(t, err.Error(), "file changed")
+	assert.Equal(t, 2, requests)
+	_, err = os.Stat(state)
+	assert.Nil(t, err)
+}
+
+func TestPushCheckpointPartialWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	state := filepath.Join(dir, "state.json")
+	data := filepath.Join(dir, "data.txt")
+	content := "a v=1\nb v=2\nc v=3\nd v=4\n"
+	assert.Nil(t, ioutil.WriteFile(data, []byte(content), 0644))
+
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		if requests == 1 {
+			rw.WriteHeader(http.StatusBadRequest)
+			rw.Write([]byte(`{"error":"partial write: points beyond retention policy dropped=1"}`))
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	opts := []func(*Pusher) error{OptWithBatchSize(1), OptWithCheckpointFile(state), OptWithResume()}
+	p, err := NewPusher(srv.URL, "d", opts...)
+	assert.Nil(t, err)
+	rep, err := p.Push(data)
+	assert.True(t, IsPartialWriteError(err))
+	assert.Equal(t, 3, rep.Batches)
+	assert.Equal(t, 4, requests)
+
+	c, err := ioutil.ReadFile(state)
+	assert.Nil(t, err)
+	cps := map[string]checkpoint{}
+	assert.Nil(t, json.Unmarshal(c, &cps))
+	abs, err := filepath.Abs(data)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, cps[abs].Batches)
+	assert.Equal(t, 4, cps[abs].Line)
+	assert.Equal(t, int64(len(content)), cps[abs].Offset)
+
+	rep, err = p.Push(data)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, rep.Batches)
+	assert.Equal(t, 4, requests)
+	_, err = os.Stat(state)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,70 @@
+package pusher
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// OptWithHTTPClient is an optional function that specifies the HTTP client
+// sending the write requests, to reuse its connection pool, proxies,
+// middlewares, ... The client's own timeout and transport are used:
+// OptWithTimeout, OptWithTransport and OptWithTLSConfig can't be specified
+// along with it.
+func OptWithHTTPClient(c *http.Client) func(*Pusher) error {
+	return func(p *Pusher) error {
+		if c == nil {
+			return fmt.Errorf("no HTTP client provided")
+		}
+		p.client = c
+		return nil
+	}
+}
+
+// OptWithTransport is an optional function that specifies the transport
+// (connection pool, dialer, proxy, tracing middleware, ...) of the HTTP
+// client sending the write requests. OptWithTLSConfig can't be specified
+// along with it, the TLS configuration being part of the transport.
+func OptWithTransport(t http.RoundTripper) func(*Pusher) error {
+	return func(p *Pusher) error {
+		if t == nil {
+			return fmt.Errorf("no transport provided")
+		}
+		p.roundTripper = t
+		return nil
+	}
+}
+
+// initClient builds, unless one has been provided, the HTTP client used by
+// every push, so that connections are reused across pushes
+func (p *Pusher) initClient() error {
+	if p.client != nil {
+		switch {
+		case p.timeout != 0:
+			return fmt.Errorf("timeout can't be used with a custom HTTP client, set the client's timeout")
+		case p.roundTripper != nil:
+			return fmt.Errorf("transport can't be used with a custom HTTP client, set the client's transport")
+		case p.tlsConfig != nil:
+			return fmt.Errorf("TLS configuration can't be used with a custom HTTP client, set it in the client's transport")
+		}
+		return nil
+	}
+	if p.roundTripper != nil && p.tlsConfig != nil {
+		return fmt.Errorf("TLS configuration can't be used with a custom transport, set it in the transport")
+	}
+	p.client = &http.Client{Timeout: p.timeout, Transport: p.transport()}
+	return nil
+}
+
+// transport returns the transport of the HTTP client, nil for the default
+// one
+func (p *Pusher) transport() http.RoundTripper {
+	if p.roundTripper != nil {
+		return p.roundTripper
+	}
+	if p.tlsConfig == nil {
+		return nil
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = p.tlsConfig
+	return t
+}
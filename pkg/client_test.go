@@ -0,0 +1,132 @@
+package pusher
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// roundTripperFunc is a http.RoundTripper implemented by a function
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestOptWithHTTPClient(t *testing.T) {
+	p := Pusher{}
+	c := &http.Client{}
+	assert.Nil(t, OptWithHTTPClient(c)(&p))
+	assert.Equal(t, c, p.client)
+	assert.NotNil(t, OptWithHTTPClient(nil)(&p))
+}
+
+func TestOptWithTransport(t *testing.T) {
+	p := Pusher{}
+	tr := &http.Transport{}
+	assert.Nil(t, OptWithTransport(tr)(&p))
+	assert.Equal(t, tr, p.roundTripper)
+	assert.NotNil(t, OptWithTransport(nil)(&p))
+}
+
+func TestNewPusherClient(t *testing.T) {
+	c := &http.Client{}
+	tr := &http.Transport{}
+	tlsConfig := &tls.Config{}
+	var tcs = []struct {
+		tcID   string
+		inOpts []func(*Pusher) error
+		expErr bool
+	}{
+		{"default", []func(*Pusher) error{OptWithTimeout(time.Second)}, false},
+		{"client", []func(*Pusher) error{OptWithHTTPClient(c)}, false},
+		{"transport", []func(*Pusher) error{OptWithTransport(tr), OptWithTimeout(time.Second)}, false},
+		{"tls", []func(*Pusher) error{OptWithTLSConfig(tlsConfig)}, false},
+		{"clientAndTimeout", []func(*Pusher) error{OptWithHTTPClient(c), OptWithTimeout(time.Second)}, true},
+		{"clientAndTransport", []func(*Pusher) error{OptWithHTTPClient(c), OptWithTransport(tr)}, true},
+		{"clientAndTLS", []func(*Pusher) error{OptWithHTTPClient(c), OptWithTLSConfig(tlsConfig)}, true},
+		{"transportAndTLS", []func(*Pusher) error{OptWithTransport(tr), OptWithTLSConfig(tlsConfig)}, true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			p, err := NewPusher("http://localhost", "d", tc.inOpts...)
+			assert.Equal(t, tc.expErr, err != nil)
+			if tc.expErr {
+				return
+			}
+			switch tc.tcID {
+			case "client":
+				assert.Equal(t, c, p.client)
+			case "transport":
+				assert.Equal(t, tr, p.client.Transport)
+				assert.Equal(t, time.Second, p.client.Timeout)
+			case "tls":
+				assert.Equal(t, tlsConfig, p.client.Transport.(*http.Transport).TLSClientConfig)
+			default:
+				assert.Nil(t, p.client.Transport)
+				assert.Equal(t, time.Second, p.client.Timeout)
+			}
+		})
+	}
+}
+
+func TestPushCustomTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "abc", req.Header.Get("X-Trace-Id"))
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	calls := int32(0)
+	tr := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		req.Header.Set("X-Trace-Id", "abc")
+		return http.DefaultTransport.RoundTrip(req)
+	})
+
+	var tcs = []struct {
+		tcID  string
+		inOpt func(*Pusher) error
+	}{
+		{"transport", OptWithTransport(tr)},
+		{"client", OptWithHTTPClient(&http.Client{Transport: tr})},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			p, err := NewPusher(srv.URL, "d", tc.inOpt, OptWithBatchSize(500))
+			assert.Nil(t, err)
+			_, err = p.Push("../testdata/sampleData.txt")
+			assert.Nil(t, err)
+			assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+		})
+	}
+}
+
+func TestPushReusesConnections(t *testing.T) {
+	conns := int32(0)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	srv.Config.ConnState = func(c net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d", OptWithTLSConfig(&tls.Config{InsecureSkipVerify: true}))
+	assert.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = p.Push("../testdata/sampleData.txt")
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+}
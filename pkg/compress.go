@@ -0,0 +1,254 @@
+package pusher
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compression is a type referring to the compression of pushed data
+type compression int
+
+const (
+	compressionNone compression = iota
+	compressionGzip
+	compressionZstd
+	compressionBzip2
+)
+
+var compressionMagics = []struct {
+	c     compression
+	magic []byte
+}{
+	{compressionGzip, []byte{0x1f, 0x8b}},
+	{compressionZstd, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{compressionBzip2, []byte("BZh")},
+}
+
+var compressionExtensions = map[string]compression{
+	".gz":   compressionGzip,
+	".gzip": compressionGzip,
+	".zst":  compressionZstd,
+	".zstd": compressionZstd,
+	".bz2":  compressionBzip2,
+}
+
+// OptWithGzip is an optional function that enables gzip compression of the
+// write requests bodies
+func OptWithGzip() func(*Pusher) error {
+	return func(p *Pusher) error {
+		p.gzip = true
+		return nil
+	}
+}
+
+// gzipReader returns a reader providing the gzip compressed content of r.
+// The compression is performed on the fly, while the returned reader is
+// consumed.
+func gzipReader(r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		zw := gzip.NewWriter(pw)
+		_, err := io.Copy(zw, r)
+		if err == nil {
+			err = zw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// detectCompression detects the compression of a file, from its first bytes
+// or, if they are not conclusive, from its name
+func detectCompression(name string, header []byte) compression {
+	for _, m := range compressionMagics {
+		if bytes.HasPrefix(header, m.magic) {
+			return m.c
+		}
+	}
+	if c, found := compressionExtensions[strings.ToLower(filepath.Ext(name))]; found {
+		return c
+	}
+	return compressionNone
+}
+
+// decompress returns a reader providing the decompressed content of r
+func decompress(r io.Reader, c compression) (io.ReadCloser, error) {
+	switch c {
+	case compressionGzip:
+		return gzip.NewReader(r)
+	case compressionZstd:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	case compressionBzip2:
+		return ioutil.NopCloser(bzip2.NewReader(r)), nil
+	}
+	return ioutil.NopCloser(r), nil
+}
+
+// Decompress returns a reader providing the decompressed content of the
+// data read from r (named name, "" if unknown), whose compression is
+// detected like the one of pushed data. Uncompressed data is provided as
+// is.
+func Decompress(name string, r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return decompress(br, detectCompression(name, header))
+}
+
+// batchSource provides the batches to push
+type batchSource interface {
+	next() (*batch, error)
+}
+
+// gzipPassthrough is a batchSource providing the content of a gzip file as a
+// single batch, sent untouched
+type gzipPassthrough struct {
+	bt *batch
+}
+
+func (g *gzipPassthrough) next() (*batch, error) {
+	if g.bt == nil {
+		return nil, io.EOF
+	}
+	bt := g.bt
+	g.bt = nil
+	return bt, nil
+}
+
+// openSource detects the compression of the data read from r (named name)
+// and returns the source of the batches to push, starting after the line
+// and offset of from, and a function that releases the resources it holds.
+// If both the data and the write requests are gzip compressed, if no batch
+// limit is set, if the data doesn't have to be converted nor bisected (dead
+// letter file) and if it is a file read from its beginning, the file is
+// streamed untouched.
+func (p *Pusher) openSource(name string, r io.Reader, from checkpoint) (batchSource, func(), error) {
+	file := section(r)
+	br := bufio.NewReader(r)
+	header, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	c := detectCompression(name, header)
+
+	if c == compressionGzip && p.gzip && p.batchSize == 0 && p.batchBytes == 0 && p.converter == nil && p.deadLetter == nil && from.Offset == 0 && file != nil {
+		return &gzipPassthrough{bt: &batch{firstLine: 1, file: file}}, func() {}, nil
+	}
+
+	dr, err := decompress(br, c)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error when decompressing data: %v", err)
+	}
+	var data io.Reader = dr
+	closeSrc := func() { dr.Close() }
+	if p.converter != nil {
+		cr, stop := p.convert(dr)
+		data = cr
+		closeSrc = func() {
+			stop()
+			dr.Close()
+		}
+	}
+	if from.Offset > 0 {
+		if _, err := io.CopyN(ioutil.Discard, data, from.Offset); err != nil {
+			closeSrc()
+			return nil, nil, fmt.Errorf("error when skipping %v byte(s): %v", from.Offset, err)
+		}
+	}
+	maxBytes := p.batchBytes
+	if p.batchSize == 0 && maxBytes == 0 {
+		maxBytes = defaultBatchBytes
+	}
+	b := newBatcher(data, p.batchSize, maxBytes)
+	b.lines, b.offset = from.Line, from.Offset
+	return b, closeSrc, nil
+}
+
+// section returns the section of r from its current offset to its end, nil
+// if r can't be read again (not a regular file, standard input, ...)
+func section(r io.Reader) *io.SectionReader {
+	f, ok := r.(interface {
+		io.ReaderAt
+		io.Seeker
+	})
+	if !ok {
+		return nil
+	}
+	start, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil
+	}
+	end, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return nil
+	}
+	return io.NewSectionReader(f, start, end-start)
+}
+
+// pointCounter counts the lines, points and uncompressed size of the gzip
+// compressed data read through it, while it is being sent
+type pointCounter struct {
+	r      io.Reader
+	pw     *io.PipeWriter
+	done   chan error
+	counts batch
+}
+
+func newPointCounter(r io.Reader) *pointCounter {
+	pr, pw := io.Pipe()
+	c := pointCounter{r: io.TeeReader(r, pw), pw: pw, done: make(chan error, 1)}
+	go func() {
+		zr, err := gzip.NewReader(pr)
+		if err == nil {
+			br := bufio.NewReader(zr)
+			for {
+				var l []byte
+				l, err = br.ReadBytes('\n')
+				c.counts.tally(l)
+				if err != nil {
+					break
+				}
+			}
+			if err == io.EOF {
+				err = nil
+			}
+		}
+		// the data that isn't counted still has to flow
+		io.Copy(ioutil.Discard, pr)
+		c.done <- err
+	}()
+	return &c
+}
+
+func (c *pointCounter) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// count waits for the counting of the data that has been read and records
+// the counts in bt if the data has been read as a whole
+func (c *pointCounter) count(bt *batch) {
+	c.pw.Close()
+	if err := <-c.done; err != nil {
+		return
+	}
+	bt.points, bt.endLine, bt.endOffset = c.counts.points, c.counts.endLine, c.counts.endOffset
+	bt.counted = true
+}
@@ -0,0 +1,226 @@
+package pusher
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptWithGzip(t *testing.T) {
+	p := Pusher{}
+	assert.Nil(t, OptWithGzip()(&p))
+	assert.True(t, p.gzip)
+}
+
+func TestGzipReader(t *testing.T) {
+	zr := gzipReader(strings.NewReader("a v=1\n"))
+	defer zr.Close()
+	r, err := gzip.NewReader(zr)
+	assert.Nil(t, err)
+	c, err := ioutil.ReadAll(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "a v=1\n", string(c))
+}
+
+func TestPushGzip(t *testing.T) {
+	expected, err := ioutil.ReadFile("../testdata/sampleData.txt")
+	assert.Nil(t, err)
+	var received []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+		r, err := gzip.NewReader(req.Body)
+		if !assert.Nil(t, err) {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		c, err := ioutil.ReadAll(r)
+		assert.Nil(t, err)
+		received = append(received, c...)
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d", OptWithGzip(), OptWithBatchSize(500))
+	assert.Nil(t, err)
+	rep, err := p.Push("../testdata/sampleData.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, rep.Batches)
+	assert.Equal(t, expected, received)
+}
+
+func TestDetectCompression(t *testing.T) {
+	var tcs = []struct {
+		tcID     string
+		inName   string
+		inHeader []byte
+		expC     compression
+	}{
+		{"gzipMagic", "data", []byte{0x1f, 0x8b, 0x08, 0x00}, compressionGzip},
+		{"zstdMagic", "data", []byte{0x28, 0xb5, 0x2f, 0xfd}, compressionZstd},
+		{"bzip2Magic", "data", []byte("BZh9"), compressionBzip2},
+		{"gzExtension", "data.gz", []byte{}, compressionGzip},
+		{"zstExtension", "data.ZST", []byte{}, compressionZstd},
+		{"bz2Extension", "data.bz2", []byte{}, compressionBzip2},
+		{"magicBeforeExtension", "data.txt", []byte{0x1f, 0x8b, 0x08, 0x00}, compressionGzip},
+		{"none", "data.txt", []byte("a v=1"), compressionNone},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			assert.Equal(t, tc.expC, detectCompression(tc.inName, tc.inHeader))
+		})
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	expected, err := ioutil.ReadFile("../testdata/sampleData.txt")
+	assert.Nil(t, err)
+	for _, f := range []string{"sampleData.txt", "sampleData.txt.gz", "sampleData.txt.zst", "sampleData.txt.bz2"} {
+		t.Run(f, func(t *testing.T) {
+			in, err := os.Open("../testdata/" + f)
+			assert.Nil(t, err)
+			defer in.Close()
+			r, err := Decompress(f, in)
+			assert.Nil(t, err)
+			defer r.Close()
+			c, err := ioutil.ReadAll(r)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, c)
+		})
+	}
+}
+
+func TestPushCompressedFiles(t *testing.T) {
+	expected, err := ioutil.ReadFile("../testdata/sampleData.txt")
+	assert.Nil(t, err)
+	var tcs = []struct {
+		tcID   string
+		inFile string
+	}{
+		{"gzip", "../testdata/sampleData.txt.gz"},
+		{"zstd", "../testdata/sampleData.txt.zst"},
+		{"bzip2", "../testdata/sampleData.txt.bz2"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			var received []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				assert.Equal(t, "", req.Header.Get("Content-Encoding"))
+				c, err := ioutil.ReadAll(req.Body)
+				assert.Nil(t, err)
+				received = append(received, c...)
+				rw.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			p, err := NewPusher(srv.URL, "d", OptWithBatchSize(500))
+			assert.Nil(t, err)
+			rep, err := p.Push(tc.inFile)
+			assert.Nil(t, err)
+			assert.Equal(t, 4, rep.Batches)
+			assert.Equal(t, 2000, rep.Points)
+			assert.Equal(t, expected, received)
+		})
+	}
+}
+
+func TestPushGzipPassthrough(t *testing.T) {
+	expected, err := ioutil.ReadFile("../testdata/sampleData.txt.gz")
+	assert.Nil(t, err)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+		c, err := ioutil.ReadAll(req.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, c)
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d", OptWithGzip())
+	assert.Nil(t, err)
+	rep, err := p.Push("../testdata/sampleData.txt.gz")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, rep.Batches)
+	assert.Equal(t, 2000, rep.Points)
+}
+
+func TestPushGzipPassthroughRetried(t *testing.T) {
+	expected, err := ioutil.ReadFile("../testdata/sampleData.txt.gz")
+	assert.Nil(t, err)
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		c, err := ioutil.ReadAll(req.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, c)
+		if requests == 1 {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d", OptWithGzip(), OptWithRetryPolicy(RetryPolicy{MaxAttempts: 2}))
+	assert.Nil(t, err)
+	rep, err := p.Push("../testdata/sampleData.txt.gz")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 1, rep.Retries)
+	assert.Equal(t, 2000, rep.Points)
+	assert.Equal(t, int64(201302), rep.Bytes)
+}
+
+func TestPushGzipNotSeekable(t *testing.T) {
+	expected, err := ioutil.ReadFile("../testdata/sampleData.txt")
+	assert.Nil(t, err)
+	var received []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		r, err := gzip.NewReader(req.Body)
+		if !assert.Nil(t, err) {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		c, err := ioutil.ReadAll(r)
+		assert.Nil(t, err)
+		received = append(received, c...)
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	f, err := os.Open("../testdata/sampleData.txt.gz")
+	assert.Nil(t, err)
+	defer f.Close()
+	p, err := NewPusher(srv.URL, "d", OptWithGzip())
+	assert.Nil(t, err)
+	rep, err := p.PushReader(context.Background(), struct{ io.Reader }{f})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, rep.Batches)
+	assert.Equal(t, 2000, rep.Points)
+	assert.Equal(t, expected, received)
+}
+
+func TestPushCorruptedCompressedFile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d")
+	assert.Nil(t, err)
+	f, err := ioutil.TempFile("", "pusher*.gz")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("a v=1\n")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	_, err = p.Push(f.Name())
+	assert.True(t, IsPusherError(err))
+}
@@ -0,0 +1,249 @@
+package convert
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/barasher/influxdb-pusher/pkg/lineprotocol"
+)
+
+// Columns of the annotated CSV produced by InfluxDB 2.x
+const (
+	annotatedMeasurement = "_measurement"
+	annotatedField       = "_field"
+	annotatedValue       = "_value"
+	annotatedTime        = "_time"
+	annotatedError       = "error"
+)
+
+// annotatedIgnored lists the columns of the query results that aren't part
+// of the points
+var annotatedIgnored = map[string]bool{"": true, "result": true, "table": true, "_start": true, "_stop": true}
+
+// annotatedTypes maps the #datatype annotations to field types
+var annotatedTypes = map[string]string{
+	"double":       lineprotocol.FieldTypeToString[lineprotocol.FieldFloat],
+	"long":         lineprotocol.FieldTypeToString[lineprotocol.FieldInteger],
+	"unsignedLong": lineprotocol.FieldTypeToString[lineprotocol.FieldUnsigned],
+	"boolean":      lineprotocol.FieldTypeToString[lineprotocol.FieldBoolean],
+}
+
+// AnnotatedCSV converts the annotated CSV produced by InfluxDB 2.x
+// (influx query --raw, /api/v2/query) into line protocol. Each table starts
+// with the #datatype, #group and #default annotations (all optional) and a
+// header row.
+//
+// If a table has _field and _value columns, each row is a point made of
+// the field, measured by _measurement, timestamped by _time and tagged by
+// the other columns. Otherwise (pivoted data), the columns of the group key
+// (#group) are tags and the other ones are fields. The result, table,
+// _start and _stop columns are ignored, as are empty cells.
+type AnnotatedCSV struct {
+	// Precision is the unit of the written timestamps, it has to match the
+	// precision of the write requests (nanosecond if 0)
+	Precision time.Duration
+}
+
+// annotatedTable is the index of the columns of a table
+type annotatedTable struct {
+	header      []string
+	datatypes   []string
+	defaults    []string
+	measurement int
+	field       int
+	value       int
+	time        int
+	err         int
+	tags        []int
+	fields      []int
+}
+
+// Convert reads annotated CSV data from r and writes line protocol to w
+func (c AnnotatedCSV) Convert(r io.Reader, w io.Writer) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	pw := newPointWriter(w)
+	annotations := map[string][]string{}
+	var tbl *annotatedTable
+	first := true
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if first {
+			row[0] = strings.TrimPrefix(row[0], "\ufeff")
+			first = false
+		}
+		line, _ := cr.FieldPos(0)
+		if strings.HasPrefix(row[0], "#") {
+			if tbl != nil {
+				tbl, annotations = nil, map[string][]string{}
+			}
+			annotations[row[0]] = row
+			continue
+		}
+		if tbl == nil {
+			if tbl, err = newAnnotatedTable(row, annotations); err != nil {
+				return fmt.Errorf("line %v: %v", line, err)
+			}
+			continue
+		}
+		if isHeader(row, tbl.header) {
+			continue
+		}
+		pt, err := tbl.point(row, c.Precision)
+		if err != nil {
+			return fmt.Errorf("line %v: %v", line, err)
+		}
+		if len(pt.Fields) == 0 {
+			continue
+		}
+		if err := pw.write(pt); err != nil {
+			return fmt.Errorf("line %v: %v", line, err)
+		}
+	}
+	return pw.flush()
+}
+
+func newAnnotatedTable(header []string, annotations map[string][]string) (*annotatedTable, error) {
+	tbl := annotatedTable{
+		header:      header,
+		datatypes:   annotations["#datatype"],
+		defaults:    annotations["#default"],
+		measurement: -1,
+		field:       -1,
+		value:       -1,
+		time:        -1,
+		err:         -1,
+	}
+	group := annotations["#group"]
+	others := []int{}
+	for i, h := range header {
+		switch {
+		case annotatedIgnored[h]:
+		case h == annotatedMeasurement:
+			tbl.measurement = i
+		case h == annotatedField:
+			tbl.field = i
+		case h == annotatedValue:
+			tbl.value = i
+		case h == annotatedTime:
+			tbl.time = i
+		case h == annotatedError:
+			tbl.err = i
+		default:
+			others = append(others, i)
+		}
+	}
+	if tbl.err >= 0 {
+		return &tbl, nil
+	}
+	if tbl.measurement < 0 {
+		return nil, fmt.Errorf("no %v column", annotatedMeasurement)
+	}
+
+	pivoted := tbl.field < 0 || tbl.value < 0
+	if pivoted && tbl.value >= 0 {
+		others = append(others, tbl.value)
+	}
+	for _, i := range others {
+		if !pivoted || annotation(group, i) == "true" {
+			tbl.tags = append(tbl.tags, i)
+		} else {
+			tbl.fields = append(tbl.fields, i)
+		}
+	}
+	if !pivoted {
+		tbl.fields = []int{tbl.value}
+	}
+	return &tbl, nil
+}
+
+// annotation returns the annotation of the column i, empty if not provided
+func annotation(a []string, i int) string {
+	if i < 0 || i >= len(a) {
+		return ""
+	}
+	return a[i]
+}
+
+// isHeader returns true if row is the header row repeated
+func isHeader(row []string, header []string) bool {
+	if len(row) != len(header) {
+		return false
+	}
+	for i := range row {
+		if row[i] != header[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (tbl *annotatedTable) point(row []string, precision time.Duration) (lineprotocol.Point, error) {
+	cell := func(i int) string {
+		if i >= 0 && i < len(row) && row[i] != "" {
+			return row[i]
+		}
+		return annotation(tbl.defaults, i)
+	}
+
+	if tbl.err >= 0 {
+		return lineprotocol.Point{}, fmt.Errorf("query error: %v", cell(tbl.err))
+	}
+	pt := lineprotocol.Point{Measurement: cell(tbl.measurement)}
+	for _, i := range tbl.tags {
+		if v := cell(i); v != "" {
+			pt.Tags = append(pt.Tags, lineprotocol.Tag{Key: tbl.header[i], Value: v})
+		}
+	}
+	for _, i := range tbl.fields {
+		v := cell(i)
+		if v == "" {
+			continue
+		}
+		key := tbl.header[i]
+		if i == tbl.value && tbl.field >= 0 {
+			key = cell(tbl.field)
+		}
+		value, err := parseValue(v, tbl.fieldType(i))
+		if err != nil {
+			return pt, fmt.Errorf("column '%v': %v", tbl.header[i], err)
+		}
+		pt.Fields = append(pt.Fields, lineprotocol.Field{Key: key, Value: value})
+	}
+	if v := cell(tbl.time); v != "" {
+		format := time.RFC3339Nano
+		if dt := annotation(tbl.datatypes, tbl.time); dt == "long" || dt == "dateTime:number" {
+			format = TimeUnixNs
+		}
+		t, err := parseTime(v, format)
+		if err != nil {
+			return pt, err
+		}
+		pt.Time, pt.HasTime = timestamp(t, precision), true
+	}
+	return pt, nil
+}
+
+// fieldType returns the type of the fields of the column i: given by the
+// #datatype annotation (string for the types that aren't field types),
+// inferred without annotation
+func (tbl *annotatedTable) fieldType(i int) string {
+	dt := annotation(tbl.datatypes, i)
+	if dt == "" {
+		return ""
+	}
+	if t, found := annotatedTypes[dt]; found {
+		return t
+	}
+	return lineprotocol.FieldTypeToString[lineprotocol.FieldString]
+}
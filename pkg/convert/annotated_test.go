@@ -0,0 +1,97 @@
+package convert
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const annotatedQuery = `#group,false,false,true,true,false,false,true,true,true
+#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,double,string,string,string
+#default,_result,,,,,,,,
+,result,table,_start,_stop,_time,_value,_field,_measurement,host
+,,0,2020-01-01T00:00:00Z,2020-01-02T00:00:00Z,2020-01-01T00:00:10Z,0.5,usage,cpu,a
+,,0,2020-01-01T00:00:00Z,2020-01-02T00:00:00Z,2020-01-01T00:00:20.5Z,1,usage,cpu,a
+
+#group,false,false,true,true,false,false,true,true,true
+#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,long,string,string,string
+#default,_result,,,,,,,,b
+,result,table,_start,_stop,_time,_value,_field,_measurement,host
+,,1,2020-01-01T00:00:00Z,2020-01-02T00:00:00Z,2020-01-01T00:00:10Z,3,procs,cpu,
+,,1,2020-01-01T00:00:00Z,2020-01-02T00:00:00Z,2020-01-01T00:00:20Z,,procs,cpu,
+
+`
+
+func TestAnnotatedCSVConvert(t *testing.T) {
+	var tcs = []struct {
+		tcID    string
+		inConv  AnnotatedCSV
+		inData  string
+		expData string
+	}{
+		{
+			"query",
+			AnnotatedCSV{},
+			annotatedQuery,
+			"cpu,host=a usage=0.5 1577836810000000000\ncpu,host=a usage=1 1577836820500000000\ncpu,host=b procs=3i 1577836810000000000\n",
+		},
+		{
+			"precision",
+			AnnotatedCSV{Precision: time.Second},
+			annotatedQuery,
+			"cpu,host=a usage=0.5 1577836810\ncpu,host=a usage=1 1577836820\ncpu,host=b procs=3i 1577836810\n",
+		},
+		{
+			"pivoted",
+			AnnotatedCSV{},
+			"#group,false,false,true,true,false,false,false,true\n#datatype,string,long,string,string,dateTime:RFC3339Nano,double,boolean,string\n#default,_result,,,,,,,\n,result,table,_measurement,host,_time,usage,idle,region\n,,0,cpu,a,2020-01-01T00:00:10.000000001Z,0.5,true,eu\n",
+			"cpu,host=a,region=eu usage=0.5,idle=true 1577836810000000001\n",
+		},
+		{
+			"withoutAnnotations",
+			AnnotatedCSV{},
+			"_measurement,_field,_value,_time,host\ncpu,usage,1.5,2020-01-01T00:00:10Z,a\ncpu,state,idle,2020-01-01T00:00:10Z,a\n_measurement,_field,_value,_time,host\n",
+			"cpu,host=a usage=1.5 1577836810000000000\ncpu,host=a state=\"idle\" 1577836810000000000\n",
+		},
+		{
+			"numberTime",
+			AnnotatedCSV{},
+			"#datatype,string,string,unsignedLong,dateTime:number\n,_measurement,_field,_value,_time\n,m,v,2,1577836810000000000\n",
+			"m v=2u 1577836810000000000\n",
+		},
+		{"empty", AnnotatedCSV{}, "", ""},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			var out bytes.Buffer
+			assert.Nil(t, tc.inConv.Convert(strings.NewReader(tc.inData), &out))
+			assert.Equal(t, tc.expData, out.String())
+		})
+	}
+}
+
+func TestAnnotatedCSVConvertErrors(t *testing.T) {
+	var tcs = []struct {
+		tcID   string
+		inData string
+		expErr string
+	}{
+		{"noMeasurement", "#datatype,string,string\n,_field,_value\n,a,1\n", "line 2: no _measurement column"},
+		{"invalidValue", "#datatype,string,string,long\n,_measurement,_field,_value\n,m,v,x\n", "line 3: column '_value': invalid integer value 'x'"},
+		{"nonFiniteValue", "#datatype,string,string,double\n,_measurement,_field,_value\n,m,v,NaN\n", "line 3: column '_value': invalid float value 'NaN'"},
+		{"invalidTime", ",_measurement,_field,_value,_time\n,m,v,1,yesterday\n", "line 2: invalid timestamp 'yesterday'"},
+		{"queryError", "#datatype,string,string\n#group,true,true\n#default,,\n,error,reference\n,failed to compile query,897\n", "line 5: query error: failed to compile query"},
+		{"emptyMeasurement", ",_measurement,_field,_value\n,,v,1\n", "line 2: no measurement"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			err := AnnotatedCSV{}.Convert(strings.NewReader(tc.inData), &bytes.Buffer{})
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), tc.expErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Package convert provides converters turning data in other formats into
+InfluxDB line protocol, to be used with pusher.OptWithConverter.
+*/
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/barasher/influxdb-pusher/pkg/lineprotocol"
+)
+
+// Timestamp formats, besides Go time layouts (time.RFC3339, ...)
+const (
+	// TimeUnix is a constant referring to Unix timestamps in seconds,
+	// possibly with a fractional part
+	TimeUnix = "unix"
+	// TimeUnixMs is a constant referring to Unix timestamps in milliseconds
+	TimeUnixMs = "unix_ms"
+	// TimeUnixUs is a constant referring to Unix timestamps in microseconds
+	TimeUnixUs = "unix_us"
+	// TimeUnixNs is a constant referring to Unix timestamps in nanoseconds
+	TimeUnixNs = "unix_ns"
+)
+
+var unixUnits = map[string]time.Duration{
+	TimeUnixMs: time.Millisecond,
+	TimeUnixUs: time.Microsecond,
+	TimeUnixNs: time.Nanosecond,
+}
+
+// Bounds of the timestamps that can be expressed in nanoseconds
+var (
+	minTime = time.Unix(0, math.MinInt64)
+	maxTime = time.Unix(0, math.MaxInt64)
+)
+
+// parseTime parses the timestamp v, expressed in the format format
+// (TimeUnix if empty)
+func parseTime(v string, format string) (time.Time, error) {
+	if format == "" || format == TimeUnix {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return time.Time{}, fmt.Errorf("invalid Unix timestamp '%v'", v)
+		}
+		if f < float64(minTime.Unix()) || f > float64(maxTime.Unix()) {
+			return time.Time{}, fmt.Errorf("timestamp '%v' out of range", v)
+		}
+		sec := int64(f)
+		return time.Unix(sec, int64((f-float64(sec))*1e9)).UTC(), nil
+	}
+	if unit, found := unixUnits[format]; found {
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid Unix timestamp '%v'", v)
+		}
+		if i > math.MaxInt64/int64(unit) || i < math.MinInt64/int64(unit) {
+			return time.Time{}, fmt.Errorf("timestamp '%v' out of range", v)
+		}
+		return time.Unix(0, i*int64(unit)).UTC(), nil
+	}
+	t, err := time.Parse(format, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp '%v': %v", v, err)
+	}
+	if t.Before(minTime) || t.After(maxTime) {
+		return time.Time{}, fmt.Errorf("timestamp '%v' out of range", v)
+	}
+	return t, nil
+}
+
+// timestamp returns the timestamp of t expressed in unit (nanosecond if 0)
+func timestamp(t time.Time, unit time.Duration) int64 {
+	if unit == 0 {
+		unit = time.Nanosecond
+	}
+	return t.UnixNano() / int64(unit)
+}
+
+var fieldTypes = map[string]lineprotocol.FieldType{}
+
+func init() {
+	for t, name := range lineprotocol.FieldTypeToString {
+		fieldTypes[name] = t
+	}
+}
+
+// checkFieldType returns an error if t is neither empty nor the name of a
+// field type
+func checkFieldType(t string) error {
+	if _, found := fieldTypes[t]; t != "" && !found {
+		return fmt.Errorf("unknown field type '%v'", t)
+	}
+	return nil
+}
+
+// parseValue parses the field value v of type t (inferred if empty)
+func parseValue(v string, t string) (interface{}, error) {
+	if t == "" {
+		return inferValue(v), nil
+	}
+	var (
+		value interface{}
+		err   error
+	)
+	switch fieldTypes[t] {
+	case lineprotocol.FieldFloat:
+		var f float64
+		// line protocol can't represent NaN and infinite values
+		if f, err = strconv.ParseFloat(v, 64); err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			err = fmt.Errorf("not a finite number")
+		}
+		value = f
+	case lineprotocol.FieldInteger:
+		value, err = strconv.ParseInt(v, 10, 64)
+	case lineprotocol.FieldUnsigned:
+		value, err = strconv.ParseUint(v, 10, 64)
+	case lineprotocol.FieldBoolean:
+		value, err = strconv.ParseBool(v)
+	default:
+		value = v
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %v value '%v'", t, v)
+	}
+	return value, nil
+}
+
+// inferValue returns v as a boolean or a float if it looks like one, as a
+// string otherwise
+func inferValue(v string) interface{} {
+	switch strings.ToLower(v) {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil && strings.IndexAny(v, "xXnNiI_") < 0 {
+		return f
+	}
+	return v
+}
+
+// pointWriter writes points as line protocol
+type pointWriter struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+func newPointWriter(w io.Writer) *pointWriter {
+	return &pointWriter{w: bufio.NewWriter(w)}
+}
+
+func (pw *pointWriter) write(pt lineprotocol.Point) error {
+	if err := pt.Check(); err != nil {
+		return err
+	}
+	pw.buf = append(pt.Append(pw.buf[:0]), '\n')
+	if strings.ContainsAny(string(pw.buf[:len(pw.buf)-1]), "\r\n") {
+		return fmt.Errorf("line break in point '%v'", pt.Measurement)
+	}
+	_, err := pw.w.Write(pw.buf)
+	return err
+}
+
+func (pw *pointWriter) flush() error {
+	return pw.w.Flush()
+}
@@ -0,0 +1,115 @@
+package convert
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/barasher/influxdb-pusher/pkg/lineprotocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTime(t *testing.T) {
+	var tcs = []struct {
+		tcID     string
+		inValue  string
+		inFormat string
+		expTime  time.Time
+		expErr   bool
+	}{
+		{"unixDefault", "1439856000", "", time.Unix(1439856000, 0), false},
+		{"unixFraction", "1439856000.5", TimeUnix, time.Unix(1439856000, 500000000), false},
+		{"unixMs", "1439856000123", TimeUnixMs, time.Unix(1439856000, 123000000), false},
+		{"unixUs", "1439856000123456", TimeUnixUs, time.Unix(1439856000, 123456000), false},
+		{"unixNs", "1439856000123456789", TimeUnixNs, time.Unix(1439856000, 123456789), false},
+		{"layout", "2015-08-18T00:00:00Z", time.RFC3339, time.Unix(1439856000, 0), false},
+		{"invalidUnix", "abc", "", time.Time{}, true},
+		{"invalidUnixMs", "1.5", TimeUnixMs, time.Time{}, true},
+		{"invalidLayout", "2015-08-18", time.RFC3339, time.Time{}, true},
+		{"unixOutOfRange", "1439856000123", TimeUnix, time.Time{}, true},
+		{"unixMsOutOfRange", "1439856000123456", TimeUnixMs, time.Time{}, true},
+		{"layoutOutOfRange", "2300-01-01T00:00:00Z", time.RFC3339, time.Time{}, true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			ti, err := parseTime(tc.inValue, tc.inFormat)
+			assert.Equal(t, tc.expErr, err != nil)
+			if !tc.expErr {
+				assert.True(t, tc.expTime.Equal(ti), "%v != %v", tc.expTime, ti)
+			}
+		})
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	ti := time.Unix(1439856000, 123456789)
+	assert.Equal(t, int64(1439856000123456789), timestamp(ti, 0))
+	assert.Equal(t, int64(1439856000123), timestamp(ti, time.Millisecond))
+	assert.Equal(t, int64(1439856000), timestamp(ti, time.Second))
+}
+
+func TestParseValue(t *testing.T) {
+	var tcs = []struct {
+		tcID     string
+		inValue  string
+		inType   string
+		expValue interface{}
+		expErr   bool
+	}{
+		{"float", "1.5", "float", 1.5, false},
+		{"integer", "-3", "integer", int64(-3), false},
+		{"unsigned", "3", "unsigned", uint64(3), false},
+		{"string", "a b", "string", "a b", false},
+		{"boolean", "true", "boolean", true, false},
+		{"inferredFloat", "1e3", "", 1000.0, false},
+		{"inferredBoolean", "FALSE", "", false, false},
+		{"inferredString", "abc", "", "abc", false},
+		{"inferredNaN", "NaN", "", "NaN", false},
+		{"inferredHex", "0x10", "", "0x10", false},
+		{"invalidFloat", "a", "float", nil, true},
+		{"floatNaN", "NaN", "float", nil, true},
+		{"floatInf", "+Inf", "float", nil, true},
+		{"invalidInteger", "1.5", "integer", nil, true},
+		{"invalidUnsigned", "-1", "unsigned", nil, true},
+		{"invalidBoolean", "yes", "boolean", nil, true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			v, err := parseValue(tc.inValue, tc.inType)
+			assert.Equal(t, tc.expErr, err != nil)
+			assert.Equal(t, tc.expValue, v)
+		})
+	}
+}
+
+func TestCheckFieldType(t *testing.T) {
+	assert.Nil(t, checkFieldType(""))
+	assert.Nil(t, checkFieldType("unsigned"))
+	assert.NotNil(t, checkFieldType("int"))
+}
+
+func TestPointWriter(t *testing.T) {
+	var tcs = []struct {
+		tcID    string
+		inPoint lineprotocol.Point
+		expLine string
+		expErr  bool
+	}{
+		{"nominal", lineprotocol.Point{Measurement: "m", Fields: []lineprotocol.Field{{Key: "v", Value: 1.0}}}, "m v=1\n", false},
+		{"noMeasurement", lineprotocol.Point{Fields: []lineprotocol.Field{{Key: "v", Value: 1.0}}}, "", true},
+		{"noField", lineprotocol.Point{Measurement: "m"}, "", true},
+		{"nan", lineprotocol.Point{Measurement: "m", Fields: []lineprotocol.Field{{Key: "v", Value: math.NaN()}}}, "", true},
+		{"lineBreak", lineprotocol.Point{Measurement: "m", Fields: []lineprotocol.Field{{Key: "v", Value: "a\nb"}}}, "", true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			var b bytes.Buffer
+			pw := newPointWriter(&b)
+			err := pw.write(tc.inPoint)
+			assert.Equal(t, tc.expErr, err != nil)
+			assert.Nil(t, pw.flush())
+			assert.Equal(t, tc.expLine, b.String())
+		})
+	}
+}
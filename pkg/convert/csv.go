@@ -0,0 +1,204 @@
+package convert
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/barasher/influxdb-pusher/pkg/lineprotocol"
+)
+
+// CSV converts CSV data, whose first row holds the column names, into line
+// protocol: each row is a point. Empty cells are ignored, rows without
+// any field are skipped.
+type CSV struct {
+	// Measurement is the measurement of the points, used if
+	// MeasurementColumn is empty
+	Measurement string
+	// MeasurementColumn is the column holding the measurement
+	MeasurementColumn string
+	// Tags lists the columns holding tags
+	Tags []string
+	// Fields lists the columns holding fields. If empty, every column that
+	// is neither the measurement, a tag nor the timestamp is a field whose
+	// type is inferred.
+	Fields []CSVField
+	// TimeColumn is the column holding the timestamp, the points have no
+	// timestamp if empty
+	TimeColumn string
+	// TimeFormat is the format of the timestamps: TimeUnix (default),
+	// TimeUnixMs, TimeUnixUs, TimeUnixNs or a Go time layout
+	TimeFormat string
+	// Precision is the unit of the written timestamps, it has to match the
+	// precision of the write requests (nanosecond if 0)
+	Precision time.Duration
+	// Comma is the field delimiter (',' if 0)
+	Comma rune
+}
+
+// CSVField is a column holding a field
+type CSVField struct {
+	// Column is the name of the column
+	Column string
+	// Type is the name of the type of the field (float, integer, unsigned,
+	// string or boolean), inferred (boolean, float or string) if empty
+	Type string
+}
+
+// csvMapping is the index of the columns of a CSV mapping
+type csvMapping struct {
+	header      []string
+	measurement int
+	tags        []int
+	fields      []int
+	types       []string
+	time        int
+}
+
+// Convert reads CSV data from r and writes line protocol to w
+func (c CSV) Convert(r io.Reader, w io.Writer) error {
+	if c.Measurement == "" && c.MeasurementColumn == "" {
+		return fmt.Errorf("no measurement provided")
+	}
+	for _, f := range c.Fields {
+		if err := checkFieldType(f.Type); err != nil {
+			return err
+		}
+	}
+
+	cr := csv.NewReader(r)
+	if c.Comma != 0 {
+		cr.Comma = c.Comma
+	}
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	m, err := c.mapping(header)
+	if err != nil {
+		return err
+	}
+
+	pw := newPointWriter(w)
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := cr.FieldPos(0)
+		pt, err := c.point(m, row)
+		if err != nil {
+			return fmt.Errorf("line %v: %v", line, err)
+		}
+		if len(pt.Fields) == 0 {
+			continue
+		}
+		if err := pw.write(pt); err != nil {
+			return fmt.Errorf("line %v: %v", line, err)
+		}
+	}
+	return pw.flush()
+}
+
+func (c CSV) mapping(header []string) (csvMapping, error) {
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	columns := map[string]int{}
+	for i, h := range header {
+		columns[h] = i
+	}
+	index := func(col string) (int, error) {
+		if col == "" {
+			return -1, nil
+		}
+		i, found := columns[col]
+		if !found {
+			return 0, fmt.Errorf("unknown column '%v'", col)
+		}
+		return i, nil
+	}
+
+	m := csvMapping{header: header}
+	var err error
+	if m.measurement, err = index(c.MeasurementColumn); err != nil {
+		return m, err
+	}
+	if m.time, err = index(c.TimeColumn); err != nil {
+		return m, err
+	}
+	used := map[int]bool{m.measurement: true, m.time: true}
+	for _, t := range c.Tags {
+		i, err := index(t)
+		if err != nil {
+			return m, err
+		}
+		m.tags = append(m.tags, i)
+		used[i] = true
+	}
+	if len(c.Fields) == 0 {
+		for i := range header {
+			if !used[i] {
+				m.fields = append(m.fields, i)
+				m.types = append(m.types, "")
+			}
+		}
+		return m, nil
+	}
+	for _, f := range c.Fields {
+		i, err := index(f.Column)
+		if err != nil {
+			return m, err
+		}
+		m.fields = append(m.fields, i)
+		m.types = append(m.types, f.Type)
+	}
+	return m, nil
+}
+
+func (c CSV) point(m csvMapping, row []string) (lineprotocol.Point, error) {
+	cell := func(i int) string {
+		if i < 0 || i >= len(row) {
+			return ""
+		}
+		return row[i]
+	}
+
+	pt := lineprotocol.Point{Measurement: c.Measurement}
+	if m.measurement >= 0 {
+		pt.Measurement = cell(m.measurement)
+	}
+	for _, i := range m.tags {
+		if v := cell(i); v != "" {
+			pt.Tags = append(pt.Tags, lineprotocol.Tag{Key: m.header[i], Value: v})
+		}
+	}
+	for j, i := range m.fields {
+		v := cell(i)
+		if v == "" {
+			continue
+		}
+		value, err := parseValue(v, m.types[j])
+		if err != nil {
+			return pt, fmt.Errorf("column '%v': %v", m.header[i], err)
+		}
+		pt.Fields = append(pt.Fields, lineprotocol.Field{Key: m.header[i], Value: value})
+	}
+	if v := cell(m.time); v != "" {
+		t, err := parseTime(v, c.TimeFormat)
+		if err != nil {
+			return pt, err
+		}
+		pt.Time, pt.HasTime = timestamp(t, c.Precision), true
+	}
+	return pt, nil
+}
@@ -0,0 +1,92 @@
+package convert
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSVConvert(t *testing.T) {
+	var tcs = []struct {
+		tcID    string
+		inConv  CSV
+		inData  string
+		expData string
+	}{
+		{
+			"inferredFields",
+			CSV{Measurement: "cpu", Tags: []string{"host"}, TimeColumn: "time"},
+			"time,host,usage,state\n1439856000,a,0.5,idle\n1439856060,b,1,true\n",
+			"cpu,host=a usage=0.5,state=\"idle\" 1439856000000000000\ncpu,host=b usage=1,state=true 1439856060000000000\n",
+		},
+		{
+			"typedFields",
+			CSV{Measurement: "cpu", Fields: []CSVField{{"count", "integer"}, {"name", "string"}}, TimeColumn: "time", Precision: time.Second},
+			"time,count,name,ignored\n1439856000,3,1.5,x\n",
+			"cpu count=3i,name=\"1.5\" 1439856000\n",
+		},
+		{
+			"measurementColumn",
+			CSV{MeasurementColumn: "m", Tags: []string{"host", "region"}},
+			"m,host,region,v\ncpu,a,eu,1\nmem,b,,2\n",
+			"cpu,host=a,region=eu v=1\nmem,host=b v=2\n",
+		},
+		{
+			"timeLayout",
+			CSV{Measurement: "m", TimeColumn: "t", TimeFormat: time.RFC3339, Precision: time.Millisecond},
+			"t,v\n2015-08-18T00:00:00.5Z,1\n",
+			"m v=1 1439856000500\n",
+		},
+		{
+			"separatorAndEscaping",
+			CSV{Measurement: "my m", Tags: []string{"host name"}, Comma: ';'},
+			"host name;level description\nhost 1;\"a \"\"b\"\"\"\n",
+			"my\\ m,host\\ name=host\\ 1 level\\ description=\"a \\\"b\\\"\"\n",
+		},
+		{
+			"emptyCellsAndRows",
+			CSV{Measurement: "m"},
+			"\ufeffa,b\n1,\n,\n",
+			"m a=1\n",
+		},
+		{"empty", CSV{Measurement: "m"}, "", ""},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			var out bytes.Buffer
+			assert.Nil(t, tc.inConv.Convert(strings.NewReader(tc.inData), &out))
+			assert.Equal(t, tc.expData, out.String())
+		})
+	}
+}
+
+func TestCSVConvertErrors(t *testing.T) {
+	var tcs = []struct {
+		tcID   string
+		inConv CSV
+		inData string
+		expErr string
+	}{
+		{"noMeasurement", CSV{}, "a\n1\n", "no measurement provided"},
+		{"unknownType", CSV{Measurement: "m", Fields: []CSVField{{"a", "int"}}}, "a\n1\n", "unknown field type 'int'"},
+		{"unknownTag", CSV{Measurement: "m", Tags: []string{"b"}}, "a\n1\n", "unknown column 'b'"},
+		{"unknownField", CSV{Measurement: "m", Fields: []CSVField{{"b", ""}}}, "a\n1\n", "unknown column 'b'"},
+		{"unknownTime", CSV{Measurement: "m", TimeColumn: "t"}, "a\n1\n", "unknown column 't'"},
+		{"invalidValue", CSV{Measurement: "m", Fields: []CSVField{{"a", "integer"}}}, "a\n1\nx\n", "line 3: column 'a': invalid integer value 'x'"},
+		{"nonFiniteValue", CSV{Measurement: "m", Fields: []CSVField{{"v", "float"}}}, "v\nNaN\n+Inf\n", "line 2: column 'v': invalid float value 'NaN'"},
+		{"invalidTime", CSV{Measurement: "m", TimeColumn: "t"}, "t,a\nx,1\n", "line 2: invalid Unix timestamp 'x'"},
+		{"emptyMeasurement", CSV{MeasurementColumn: "m"}, "m,a\n,1\n", "line 2: no measurement"},
+		{"malformedCSV", CSV{Measurement: "m"}, "a\n\"1\n", "extraneous or missing \" in quoted-field"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			err := tc.inConv.Convert(strings.NewReader(tc.inData), &bytes.Buffer{})
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), tc.expErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/barasher/influxdb-pusher/pkg/lineprotocol"
+)
+
+// Parts of the Graphite templates
+const (
+	graphiteMeasurement     = "measurement"
+	graphiteMeasurementTail = "measurement*"
+	graphiteField           = "field"
+	graphiteFieldTail       = "field*"
+)
+
+// graphiteDefaultTemplate is the template used when no template matches
+var graphiteDefaultTemplate = graphiteTemplate{parts: []string{graphiteMeasurementTail}}
+
+// Graphite converts the Graphite plaintext protocol (path value timestamp)
+// into line protocol, the dotted paths being split into measurement, tags
+// and field by templates, as Telegraf does.
+//
+// A template is made of an optional filter, the template itself and
+// optional default tags, separated by spaces: "cpu.* host.measurement.field
+// region=eu,env=prod". Each part of the template names the part of the path
+// at the same position: measurement, field, a tag key or nothing (empty
+// part, skipped). The measurement* and field* parts take all the remaining
+// parts of the path. The parts of the measurement and of the field are
+// joined with Separator, the field is named value if the template has no
+// field part. The filter is a pattern matched against the first parts of
+// the path, part by part (cpu.*.load matches cpu.host1.load.1m). The first
+// template whose filter matches is used, the templates without filter
+// being the defaults (measurement* if none).
+//
+// Graphite 1.1 tags (path;tag1=value1;tag2=value2) are tags too, and
+// timestamps are Unix timestamps in seconds (-1 or none: no timestamp).
+type Graphite struct {
+	// Templates lists the templates, measurement* if empty
+	Templates []string
+	// Separator joins the parts of the measurements and fields ("." if
+	// empty)
+	Separator string
+	// Precision is the unit of the written timestamps, it has to match the
+	// precision of the write requests (nanosecond if 0)
+	Precision time.Duration
+}
+
+// graphiteTemplate is a parsed template
+type graphiteTemplate struct {
+	filter []string
+	parts  []string
+	tags   []lineprotocol.Tag
+}
+
+// CheckTemplates returns an error if a template is invalid
+func (c Graphite) CheckTemplates() error {
+	_, err := c.templates()
+	return err
+}
+
+func (c Graphite) templates() ([]graphiteTemplate, error) {
+	templates := make([]graphiteTemplate, 0, len(c.Templates))
+	for _, t := range c.Templates {
+		tmpl, err := parseGraphiteTemplate(t)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, tmpl)
+	}
+	return templates, nil
+}
+
+// Convert reads Graphite metrics from r and writes line protocol to w
+func (c Graphite) Convert(r io.Reader, w io.Writer) error {
+	templates, err := c.templates()
+	if err != nil {
+		return err
+	}
+
+	br := bufio.NewReader(r)
+	pw := newPointWriter(w)
+	for line := 1; ; line++ {
+		l, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if l == "" && err == io.EOF {
+			break
+		}
+		if l = strings.TrimSpace(l); l != "" {
+			pt, err := c.point(l, templates)
+			if err != nil {
+				return fmt.Errorf("line %v: %v", line, err)
+			}
+			if err := pw.write(pt); err != nil {
+				return fmt.Errorf("line %v: %v", line, err)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return pw.flush()
+}
+
+// parseGraphiteTemplate parses the template t: [filter] template [tags]
+func parseGraphiteTemplate(t string) (graphiteTemplate, error) {
+	tmpl := graphiteTemplate{}
+	elts := strings.Fields(t)
+	if len(elts) > 1 && strings.Contains(elts[len(elts)-1], "=") {
+		for _, tag := range strings.Split(elts[len(elts)-1], ",") {
+			kv := strings.SplitN(tag, "=", 2)
+			if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+				return tmpl, fmt.Errorf("invalid tag '%v' of template '%v'", tag, t)
+			}
+			tmpl.tags = append(tmpl.tags, lineprotocol.Tag{Key: kv[0], Value: kv[1]})
+		}
+		elts = elts[:len(elts)-1]
+	}
+	switch len(elts) {
+	case 1:
+		tmpl.parts = strings.Split(elts[0], ".")
+	case 2:
+		tmpl.filter = strings.Split(elts[0], ".")
+		tmpl.parts = strings.Split(elts[1], ".")
+		for _, f := range tmpl.filter {
+			if _, err := path.Match(f, ""); err != nil {
+				return tmpl, fmt.Errorf("invalid filter of template '%v': %v", t, err)
+			}
+		}
+	default:
+		return tmpl, fmt.Errorf("invalid template '%v'", t)
+	}
+	for _, p := range tmpl.parts {
+		if p == graphiteMeasurement || p == graphiteMeasurementTail {
+			return tmpl, nil
+		}
+	}
+	return tmpl, fmt.Errorf("no measurement in template '%v'", t)
+}
+
+// matches returns true if the filter of the template matches the parts of
+// the path
+func (tmpl graphiteTemplate) matches(parts []string) bool {
+	if len(tmpl.filter) > len(parts) {
+		return false
+	}
+	for i, f := range tmpl.filter {
+		if ok, _ := path.Match(f, parts[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// template returns the template applying to the parts of the path
+func template(templates []graphiteTemplate, parts []string) graphiteTemplate {
+	var def *graphiteTemplate
+	for i, tmpl := range templates {
+		if tmpl.filter == nil {
+			if def == nil {
+				def = &templates[i]
+			}
+			continue
+		}
+		if tmpl.matches(parts) {
+			return tmpl
+		}
+	}
+	if def != nil {
+		return *def
+	}
+	return graphiteDefaultTemplate
+}
+
+func (c Graphite) point(l string, templates []graphiteTemplate) (lineprotocol.Point, error) {
+	pt := lineprotocol.Point{}
+	elts := strings.Fields(l)
+	if len(elts) < 2 || len(elts) > 3 {
+		return pt, fmt.Errorf("invalid metric '%v'", l)
+	}
+	v, err := strconv.ParseFloat(elts[1], 64)
+	if err != nil || strings.IndexAny(elts[1], "xXnNiI_") >= 0 {
+		return pt, fmt.Errorf("invalid value '%v'", elts[1])
+	}
+	if len(elts) == 3 && elts[2] != "-1" {
+		t, err := parseTime(elts[2], TimeUnix)
+		if err != nil {
+			return pt, err
+		}
+		pt.Time, pt.HasTime = timestamp(t, c.Precision), true
+	}
+
+	name := strings.Split(elts[0], ";")
+	tags := map[string]string{}
+	for _, tag := range name[1:] {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return pt, fmt.Errorf("invalid tag '%v'", tag)
+		}
+		tags[kv[0]] = kv[1]
+	}
+	parts := strings.Split(name[0], ".")
+	tmpl := template(templates, parts)
+	for _, tag := range tmpl.tags {
+		if _, found := tags[tag.Key]; !found {
+			tags[tag.Key] = tag.Value
+		}
+	}
+
+	sep := c.Separator
+	if sep == "" {
+		sep = "."
+	}
+	var measurement, field []string
+	pathTags := map[string][]string{}
+	for i, p := range tmpl.parts {
+		if i >= len(parts) {
+			break
+		}
+		switch p {
+		case "":
+		case graphiteMeasurement:
+			measurement = append(measurement, parts[i])
+		case graphiteMeasurementTail:
+			measurement = append(measurement, parts[i:]...)
+		case graphiteField:
+			field = append(field, parts[i])
+		case graphiteFieldTail:
+			field = append(field, parts[i:]...)
+		default:
+			pathTags[p] = append(pathTags[p], parts[i])
+		}
+		if p == graphiteMeasurementTail || p == graphiteFieldTail {
+			break
+		}
+	}
+	for k, v := range pathTags {
+		tags[k] = strings.Join(v, sep)
+	}
+
+	pt.Measurement = strings.Join(measurement, sep)
+	if pt.Measurement == "" {
+		pt.Measurement = name[0]
+	}
+	for k, v := range tags {
+		pt.Tags = append(pt.Tags, lineprotocol.Tag{Key: k, Value: v})
+	}
+	sort.Slice(pt.Tags, func(i, j int) bool { return pt.Tags[i].Key < pt.Tags[j].Key })
+	fieldKey := strings.Join(field, sep)
+	if fieldKey == "" {
+		fieldKey = "value"
+	}
+	pt.Fields = []lineprotocol.Field{{Key: fieldKey, Value: v}}
+	return pt, nil
+}
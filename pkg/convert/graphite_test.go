@@ -0,0 +1,93 @@
+package convert
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphiteConvert(t *testing.T) {
+	var tcs = []struct {
+		tcID    string
+		inConv  Graphite
+		inData  string
+		expData string
+	}{
+		{
+			"defaultTemplate",
+			Graphite{},
+			"servers.host1.cpu.load 0.5 1439856000\n\nservers.host2.cpu.load 1 -1\n",
+			"servers.host1.cpu.load value=0.5 1439856000000000000\nservers.host2.cpu.load value=1\n",
+		},
+		{
+			"template",
+			Graphite{Templates: []string{"host.measurement.field*"}, Precision: time.Second},
+			"host1.cpu.load.1m 0.5 1439856000\nhost2.mem.free 1024 1439856000\n",
+			"cpu,host=host1 load.1m=0.5 1439856000\nmem,host=host2 free=1024 1439856000\n",
+		},
+		{
+			"filters",
+			Graphite{
+				Templates: []string{"servers.* .host.measurement.field env=prod,dc=eu", "apps.*.requests .app..measurement* dc=us", "measurement.measurement.field*"},
+				Separator: "_",
+			},
+			"servers.h1.cpu.idle 90\napps.shop.requests.http.get 3\nstats.gauges.mem.free 1\n",
+			"cpu,dc=eu,env=prod,host=h1 idle=90\nhttp_get,app=shop,dc=us value=3\nstats_gauges mem_free=1\n",
+		},
+		{
+			"multipleParts",
+			Graphite{Templates: []string{"region.region.host.measurement.measurement"}},
+			"eu.west.h1.disk.sda 2\neu.west 3\n",
+			"disk.sda,host=h1,region=eu.west value=2\neu.west,region=eu.west value=3\n",
+		},
+		{
+			"graphiteTags",
+			Graphite{Templates: []string{"measurement.field dc=eu"}},
+			"cpu.idle;host=h1;dc=us 90\n",
+			"cpu,dc=us,host=h1 idle=90\n",
+		},
+		{"empty", Graphite{}, "", ""},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			var out bytes.Buffer
+			assert.Nil(t, tc.inConv.Convert(strings.NewReader(tc.inData), &out))
+			assert.Equal(t, tc.expData, out.String())
+		})
+	}
+}
+
+func TestGraphiteCheckTemplates(t *testing.T) {
+	assert.Nil(t, Graphite{}.CheckTemplates())
+	assert.Nil(t, Graphite{Templates: []string{"cpu.* host.measurement.field* env=prod", "measurement*"}}.CheckTemplates())
+	assert.NotNil(t, Graphite{Templates: []string{"measurement*", "host.field"}}.CheckTemplates())
+}
+
+func TestGraphiteConvertErrors(t *testing.T) {
+	var tcs = []struct {
+		tcID        string
+		inTemplates []string
+		inData      string
+		expErr      string
+	}{
+		{"noMeasurement", []string{"host.field"}, "", "no measurement in template 'host.field'"},
+		{"invalidTemplate", []string{"a b c d"}, "", "invalid template 'a b c d'"},
+		{"invalidTemplateTag", []string{"measurement a=1,b"}, "", "invalid tag 'b' of template 'measurement a=1,b'"},
+		{"invalidFilter", []string{"[a measurement"}, "", "invalid filter of template '[a measurement'"},
+		{"noValue", nil, "a.b\n", "line 1: invalid metric 'a.b'"},
+		{"invalidValue", nil, "a.b 1\na.b NaN\n", "line 2: invalid value 'NaN'"},
+		{"invalidTimestamp", nil, "a.b 1 x\n", "line 1: invalid Unix timestamp 'x'"},
+		{"invalidTag", nil, "a.b;c 1\n", "line 1: invalid tag 'c'"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			err := Graphite{Templates: tc.inTemplates}.Convert(strings.NewReader(tc.inData), &bytes.Buffer{})
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), tc.expErr)
+			}
+		})
+	}
+}
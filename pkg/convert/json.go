@@ -0,0 +1,317 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/barasher/influxdb-pusher/pkg/lineprotocol"
+)
+
+// JSON converts JSON documents into line protocol: each document is a
+// point. The documents can be newline-delimited (NDJSON), concatenated or
+// the elements of a top-level array. Values are referred to by paths whose
+// elements are separated by dots (host.name, values.0). Null values are
+// ignored, documents without any field are skipped.
+type JSON struct {
+	// Measurement is the measurement of the points, used if
+	// MeasurementPath is empty
+	Measurement string
+	// MeasurementPath is the path of the measurement
+	MeasurementPath string
+	// Tags lists the paths of the tags, whose key is the path (its elements
+	// joined by Separator)
+	Tags []string
+	// Fields lists the paths of the fields, whose key is the path. Objects
+	// and arrays are flattened, the keys of their values being suffixed with
+	// Separator and the key or index of the value. If empty, every value
+	// that is neither the measurement, a tag nor the timestamp is a field
+	// whose type is inferred.
+	Fields []JSONField
+	// TimePath is the path of the timestamp, the points have no timestamp if
+	// empty
+	TimePath string
+	// TimeFormat is the format of the timestamps: TimeUnix (default),
+	// TimeUnixMs, TimeUnixUs, TimeUnixNs or a Go time layout
+	TimeFormat string
+	// Precision is the unit of the written timestamps, it has to match the
+	// precision of the write requests (nanosecond if 0)
+	Precision time.Duration
+	// Separator joins the elements of the paths in the keys of the tags and
+	// fields ("_" if empty)
+	Separator string
+	// Explode is the path of an array exploded into multiple points: each
+	// element makes a point, the element replacing the array in the document
+	// (the paths starting with Explode refer to the element)
+	Explode string
+}
+
+// JSONField is a path holding a field
+type JSONField struct {
+	// Path is the path of the field
+	Path string
+	// Type is the name of the type of the field (float, integer, unsigned,
+	// string or boolean), inferred (float, string or boolean) if empty
+	Type string
+}
+
+// Convert reads JSON documents from r and writes line protocol to w
+func (c JSON) Convert(r io.Reader, w io.Writer) error {
+	if c.Measurement == "" && c.MeasurementPath == "" {
+		return fmt.Errorf("no measurement provided")
+	}
+	for _, f := range c.Fields {
+		if err := checkFieldType(f.Type); err != nil {
+			return err
+		}
+	}
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	pw := newPointWriter(w)
+	n := 0
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("document %v: %v", n+1, err)
+		}
+		docs := []interface{}{v}
+		if a, ok := v.([]interface{}); ok {
+			docs = a
+		}
+		for _, doc := range docs {
+			n++
+			if err := c.convertDocument(pw, doc); err != nil {
+				return fmt.Errorf("document %v: %v", n, err)
+			}
+		}
+	}
+	return pw.flush()
+}
+
+func (c JSON) convertDocument(pw *pointWriter, doc interface{}) error {
+	docs := []interface{}{doc}
+	if c.Explode != "" {
+		var err error
+		if docs, err = explode(doc, splitPath(c.Explode)); err != nil {
+			return err
+		}
+	}
+	for _, d := range docs {
+		pt, err := c.point(d)
+		if err != nil {
+			return err
+		}
+		if len(pt.Fields) == 0 {
+			continue
+		}
+		if err := pw.write(pt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c JSON) point(doc interface{}) (lineprotocol.Point, error) {
+	pt := lineprotocol.Point{Measurement: c.Measurement}
+	if c.MeasurementPath != "" {
+		v, err := scalar(doc, c.MeasurementPath)
+		if err != nil {
+			return pt, err
+		}
+		pt.Measurement = v
+	}
+	for _, path := range c.Tags {
+		v, err := scalar(doc, path)
+		if err != nil {
+			return pt, err
+		}
+		if v != "" {
+			pt.Tags = append(pt.Tags, lineprotocol.Tag{Key: c.key(splitPath(path)), Value: v})
+		}
+	}
+
+	if len(c.Fields) == 0 {
+		used := []string{c.MeasurementPath, c.TimePath}
+		used = append(used, c.Tags...)
+		if err := c.appendFields(&pt, nil, doc, "", used); err != nil {
+			return pt, err
+		}
+	}
+	for _, f := range c.Fields {
+		path := splitPath(f.Path)
+		if err := c.appendFields(&pt, path, lookup(doc, path), f.Type, nil); err != nil {
+			return pt, err
+		}
+	}
+
+	if c.TimePath != "" {
+		v, err := scalar(doc, c.TimePath)
+		if err != nil {
+			return pt, err
+		}
+		if v != "" {
+			t, err := parseTime(v, c.TimeFormat)
+			if err != nil {
+				return pt, err
+			}
+			pt.Time, pt.HasTime = timestamp(t, c.Precision), true
+		}
+	}
+	return pt, nil
+}
+
+// appendFields appends to pt the fields of the value v found at path,
+// flattening objects and arrays, skipping the values under the paths of
+// excluded
+func (c JSON) appendFields(pt *lineprotocol.Point, path []string, v interface{}, typeName string, excluded []string) error {
+	p := strings.Join(path, ".")
+	for _, e := range excluded {
+		if e != "" && (p == e || strings.HasPrefix(p, e+".")) {
+			return nil
+		}
+	}
+	switch value := v.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := c.appendFields(pt, append(path[:len(path):len(path)], k), value[k], typeName, excluded); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		for i, e := range value {
+			if err := c.appendFields(pt, append(path[:len(path):len(path)], strconv.Itoa(i)), e, typeName, excluded); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	key := c.key(path)
+	var (
+		value interface{}
+		err   error
+	)
+	if s, isString := v.(string); isString && typeName == "" {
+		value = s
+	} else if b, isBool := v.(bool); isBool && typeName == "" {
+		value = b
+	} else {
+		t := typeName
+		if t == "" {
+			t = lineprotocol.FieldTypeToString[lineprotocol.FieldFloat]
+		}
+		if value, err = parseValue(fmt.Sprint(v), t); err != nil {
+			return fmt.Errorf("path '%v': %v", p, err)
+		}
+	}
+	pt.Fields = append(pt.Fields, lineprotocol.Field{Key: key, Value: value})
+	return nil
+}
+
+// key returns the key of the tag or field found at path
+func (c JSON) key(path []string) string {
+	sep := c.Separator
+	if sep == "" {
+		sep = "_"
+	}
+	return strings.Join(path, sep)
+}
+
+// splitPath splits path into its elements
+func splitPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// lookup returns the value found at path in v, nil if not found
+func lookup(v interface{}, path []string) interface{} {
+	for _, elt := range path {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			v = value[elt]
+		case []interface{}:
+			i, err := strconv.Atoi(elt)
+			if err != nil || i < 0 || i >= len(value) {
+				return nil
+			}
+			v = value[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// scalar returns the string representation of the scalar value found at
+// path in v, empty if not found
+func scalar(v interface{}, path string) (string, error) {
+	switch value := lookup(v, splitPath(path)).(type) {
+	case nil:
+		return "", nil
+	case map[string]interface{}, []interface{}:
+		return "", fmt.Errorf("path '%v' isn't a scalar value", path)
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
+
+// explode returns a copy of doc per element of the array found at path,
+// the element replacing the array
+func explode(doc interface{}, path []string) ([]interface{}, error) {
+	v := lookup(doc, path)
+	if v == nil {
+		return nil, nil
+	}
+	a, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("path '%v' isn't an array", strings.Join(path, "."))
+	}
+	docs := make([]interface{}, 0, len(a))
+	for _, e := range a {
+		docs = append(docs, replace(doc, path, e))
+	}
+	return docs, nil
+}
+
+// replace returns a copy of v where the value found at path is replaced by
+// r, the objects and arrays along path being copied
+func replace(v interface{}, path []string, r interface{}) interface{} {
+	if len(path) == 0 {
+		return r
+	}
+	switch value := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(value))
+		for k, e := range value {
+			c[k] = e
+		}
+		c[path[0]] = replace(value[path[0]], path[1:], r)
+		return c
+	case []interface{}:
+		i, _ := strconv.Atoi(path[0])
+		c := append([]interface{}{}, value...)
+		c[i] = replace(value[i], path[1:], r)
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,85 @@
+package convert
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONConvert(t *testing.T) {
+	var tcs = []struct {
+		tcID    string
+		inConv  JSON
+		inData  string
+		expData string
+	}{
+		{
+			"ndjson",
+			JSON{Measurement: "logs", Tags: []string{"host.name"}, TimePath: "ts"},
+			"{\"ts\":1439856000,\"host\":{\"name\":\"a\"},\"level\":\"info\",\"duration\":0.5,\"ok\":true}\n{\"ts\":1439856060,\"host\":{\"name\":\"b\"},\"level\":\"error\",\"duration\":2,\"ok\":false,\"extra\":null}\n",
+			"logs,host_name=a duration=0.5,level=\"info\",ok=true 1439856000000000000\nlogs,host_name=b duration=2,level=\"error\",ok=false 1439856060000000000\n",
+		},
+		{
+			"array",
+			JSON{MeasurementPath: "m", Separator: "."},
+			"[{\"m\":\"cpu\",\"v\":1},{\"m\":\"mem\",\"v\":2}]",
+			"cpu v=1\nmem v=2\n",
+		},
+		{
+			"flattening",
+			JSON{Measurement: "m", Fields: []JSONField{{"stats", "integer"}, {"name", ""}}, Separator: "."},
+			"{\"stats\":{\"read\":{\"count\":3},\"sizes\":[10,20]},\"name\":\"x\",\"ignored\":1}",
+			"m stats.read.count=3i,stats.sizes.0=10i,stats.sizes.1=20i,name=\"x\"\n",
+		},
+		{
+			"explode",
+			JSON{Measurement: "sensors", Tags: []string{"device", "readings.sensor"}, Fields: []JSONField{{"readings.value", "float"}}, TimePath: "readings.time", TimeFormat: time.RFC3339, Precision: time.Second, Explode: "readings"},
+			"{\"device\":\"d1\",\"readings\":[{\"sensor\":\"t\",\"value\":21,\"time\":\"2015-08-18T00:00:00Z\"},{\"sensor\":\"h\",\"value\":40.5,\"time\":\"2015-08-18T00:00:01Z\"}]}\n{\"device\":\"d2\"}\n",
+			"sensors,device=d1,readings_sensor=t readings_value=21 1439856000\nsensors,device=d1,readings_sensor=h readings_value=40.5 1439856001\n",
+		},
+		{
+			"typesAndEscaping",
+			JSON{Measurement: "my m", Tags: []string{"tag key"}, Fields: []JSONField{{"count", "unsigned"}, {"id", "string"}, {"flag", "boolean"}, {"text", ""}}},
+			"{\"tag key\":\"a,b\",\"count\":12345678901234567890,\"id\":42,\"flag\":\"true\",\"text\":\"say \\\"hi\\\"\"}",
+			"my\\ m,tag\\ key=a\\,b count=12345678901234567890u,id=\"42\",flag=true,text=\"say \\\"hi\\\"\"\n",
+		},
+		{"empty", JSON{Measurement: "m"}, "", ""},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			var out bytes.Buffer
+			assert.Nil(t, tc.inConv.Convert(strings.NewReader(tc.inData), &out))
+			assert.Equal(t, tc.expData, out.String())
+		})
+	}
+}
+
+func TestJSONConvertErrors(t *testing.T) {
+	var tcs = []struct {
+		tcID   string
+		inConv JSON
+		inData string
+		expErr string
+	}{
+		{"noMeasurement", JSON{}, "{}", "no measurement provided"},
+		{"unknownType", JSON{Measurement: "m", Fields: []JSONField{{"a", "int"}}}, "{}", "unknown field type 'int'"},
+		{"invalidJSON", JSON{Measurement: "m"}, "{\"a\":1}\n{\"a\":", "document 2: unexpected EOF"},
+		{"invalidValue", JSON{Measurement: "m", Fields: []JSONField{{"a", "integer"}}}, "{\"a\":1.5}", "document 1: path 'a': invalid integer value '1.5'"},
+		{"nonFiniteValue", JSON{Measurement: "m", Fields: []JSONField{{"a", "float"}}}, "{\"a\":\"Inf\"}", "document 1: path 'a': invalid float value 'Inf'"},
+		{"objectTag", JSON{Measurement: "m", Tags: []string{"a"}}, "{\"a\":{\"b\":1},\"v\":1}", "document 1: path 'a' isn't a scalar value"},
+		{"invalidTime", JSON{Measurement: "m", TimePath: "t"}, "{\"t\":\"now\",\"v\":1}", "document 1: invalid Unix timestamp 'now'"},
+		{"notAnArray", JSON{Measurement: "m", Explode: "a"}, "{\"a\":1}", "document 1: path 'a' isn't an array"},
+		{"lineBreak", JSON{Measurement: "m"}, "{\"a\":\"x\\ny\"}", "document 1: line break in point 'm'"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			err := tc.inConv.Convert(strings.NewReader(tc.inData), &bytes.Buffer{})
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), tc.expErr)
+			}
+		})
+	}
+}
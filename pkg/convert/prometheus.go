@@ -0,0 +1,318 @@
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/barasher/influxdb-pusher/pkg/lineprotocol"
+)
+
+// Prometheus metric types
+const (
+	promCounter        = "counter"
+	promGauge          = "gauge"
+	promHistogram      = "histogram"
+	promGaugeHistogram = "gaugehistogram"
+	promSummary        = "summary"
+	promUntyped        = "untyped"
+)
+
+// promSuffixes lists the suffixes of the samples of the metric types made
+// of several series, and the field they are mapped to
+var promSuffixes = map[string]map[string]string{
+	promHistogram:      {"_bucket": "", "_sum": "sum", "_count": "count"},
+	promGaugeHistogram: {"_bucket": "", "_gsum": "sum", "_gcount": "count"},
+	promSummary:        {"_sum": "sum", "_count": "count"},
+	promCounter:        {"_total": promCounter, "_created": ""},
+}
+
+// Prometheus converts the Prometheus text exposition format (the content
+// of /metrics endpoints) or its OpenMetrics flavour into line protocol.
+// Labels are tags and samples are fields: each metric is a measurement
+// whose field is named after its type (counter, gauge or value), the
+// samples of histograms and summaries sharing the same labels and
+// timestamp making a single point with count, sum and a field per bucket
+// (named after its upper bound) or quantile. If Measurement is set, all the
+// metrics are fields of this measurement, named after the metric
+// (histograms and summaries expanding into <name>_count, <name>_sum,
+// <name>_bucket_<bound> and <name>_quantile_<quantile> fields).
+//
+// Sample timestamps are honored when present. NaN and infinite values,
+// which line protocol can't represent, are ignored, as well as the
+// _created samples and the exemplars of OpenMetrics.
+type Prometheus struct {
+	// Measurement is the measurement of all the points, each metric being a
+	// measurement if empty
+	Measurement string
+	// OpenMetrics has to be true if the timestamps are expressed in seconds
+	// (OpenMetrics) rather than in milliseconds (Prometheus)
+	OpenMetrics bool
+	// Precision is the unit of the written timestamps, it has to match the
+	// precision of the write requests (nanosecond if 0)
+	Precision time.Duration
+}
+
+// promSample is a sample of a metric
+type promSample struct {
+	name    string
+	labels  []lineprotocol.Tag
+	value   float64
+	time    time.Time
+	hasTime bool
+}
+
+// promFamily gathers the points of a metric
+type promFamily struct {
+	name   string
+	points []*lineprotocol.Point
+	index  map[string]int
+}
+
+// Convert reads Prometheus metrics from r and writes line protocol to w
+func (c Prometheus) Convert(r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+	pw := newPointWriter(w)
+	types := map[string]string{}
+	var family *promFamily
+	flush := func() error {
+		if family == nil {
+			return nil
+		}
+		for _, pt := range family.points {
+			if err := pw.write(*pt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for line := 1; ; line++ {
+		l, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if l == "" && err == io.EOF {
+			break
+		}
+		l = strings.TrimSpace(l)
+		if strings.HasPrefix(l, "#") {
+			fields := strings.Fields(l)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				types[fields[2]] = fields[3]
+			}
+			if len(fields) == 2 && fields[1] == "EOF" {
+				break
+			}
+			continue
+		}
+		if l != "" {
+			s, err := c.parseSample(l)
+			if err != nil {
+				return fmt.Errorf("line %v: %v", line, err)
+			}
+			name, typ, field := promField(s, types)
+			if family == nil || family.name != name {
+				if err := flush(); err != nil {
+					return fmt.Errorf("line %v: %v", line, err)
+				}
+				family = &promFamily{name: name, index: map[string]int{}}
+			}
+			if field != "" && !math.IsNaN(s.value) && !math.IsInf(s.value, 0) {
+				c.add(family, s, typ, field)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	return pw.flush()
+}
+
+// promField returns the metric, the metric type and the field of the
+// sample s (empty if the sample is ignored), according to the declared
+// types
+func promField(s promSample, types map[string]string) (string, string, string) {
+	if typ, found := types[s.name]; found {
+		switch typ {
+		case promCounter, promGauge:
+			return s.name, typ, typ
+		case promSummary:
+			if quantile := promLabel(s, "quantile"); quantile != "" {
+				return s.name, typ, quantile
+			}
+		default:
+			return s.name, promUntyped, "value"
+		}
+	}
+	for typ, suffixes := range promSuffixes {
+		for suffix, field := range suffixes {
+			name := strings.TrimSuffix(s.name, suffix)
+			if name == s.name || types[name] != typ {
+				continue
+			}
+			if suffix == "_bucket" {
+				field = promLabel(s, "le")
+			}
+			return name, typ, field
+		}
+	}
+	return s.name, promUntyped, "value"
+}
+
+// promLabel returns the value of the label of s, empty if not found
+func promLabel(s promSample, label string) string {
+	for _, l := range s.labels {
+		if l.Key == label {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+// add adds the sample s of the metric family to the point of its labels
+// and timestamp
+func (c Prometheus) add(family *promFamily, s promSample, typ string, field string) {
+	tags := make([]lineprotocol.Tag, 0, len(s.labels))
+	for _, l := range s.labels {
+		if (l.Key == "le" && (typ == promHistogram || typ == promGaugeHistogram)) || (l.Key == "quantile" && typ == promSummary) {
+			continue
+		}
+		tags = append(tags, l)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+
+	var t int64
+	if s.hasTime {
+		t = timestamp(s.time, c.Precision)
+	}
+	var key strings.Builder
+	for _, tag := range tags {
+		fmt.Fprintf(&key, "%v\x00%v\x00", tag.Key, tag.Value)
+	}
+	fmt.Fprintf(&key, "%v %v", s.hasTime, t)
+	i, found := family.index[key.String()]
+	if !found {
+		pt := lineprotocol.Point{Measurement: family.name, Tags: tags, Time: t, HasTime: s.hasTime}
+		if c.Measurement != "" {
+			pt.Measurement = c.Measurement
+		}
+		i = len(family.points)
+		family.index[key.String()] = i
+		family.points = append(family.points, &pt)
+	}
+
+	if c.Measurement != "" {
+		switch {
+		case typ == promCounter || typ == promGauge || typ == promUntyped:
+			field = family.name
+		case field == "sum" || field == "count":
+			field = family.name + "_" + field
+		case typ == promSummary:
+			field = family.name + "_quantile_" + field
+		default:
+			field = family.name + "_bucket_" + field
+		}
+	}
+	pt := family.points[i]
+	pt.Fields = append(pt.Fields, lineprotocol.Field{Key: field, Value: s.value})
+}
+
+// parseSample parses the sample line l: name{labels} value [timestamp]
+func (c Prometheus) parseSample(l string) (promSample, error) {
+	s := promSample{}
+	i := strings.IndexAny(l, "{ \t")
+	if i < 0 {
+		return s, fmt.Errorf("no value")
+	}
+	s.name, l = l[:i], l[i:]
+	if s.name == "" {
+		return s, fmt.Errorf("no metric name")
+	}
+	if l[0] == '{' {
+		var err error
+		if s.labels, l, err = parseLabels(l[1:]); err != nil {
+			return s, err
+		}
+	}
+
+	if i := strings.Index(l, "#"); c.OpenMetrics && i >= 0 {
+		l = l[:i]
+	}
+	values := strings.Fields(l)
+	if len(values) == 0 || len(values) > 2 {
+		return s, fmt.Errorf("invalid sample '%v'", strings.TrimSpace(l))
+	}
+	v, err := strconv.ParseFloat(values[0], 64)
+	if err != nil {
+		return s, fmt.Errorf("invalid value '%v'", values[0])
+	}
+	s.value = v
+	if len(values) == 2 {
+		if c.OpenMetrics {
+			s.time, err = parseTime(values[1], TimeUnix)
+		} else {
+			s.time, err = parseTime(values[1], TimeUnixMs)
+		}
+		if err != nil {
+			return s, err
+		}
+		s.hasTime = true
+	}
+	return s, nil
+}
+
+// parseLabels parses the labels following the opening brace and returns
+// them with the rest of the line
+func parseLabels(l string) ([]lineprotocol.Tag, string, error) {
+	labels := []lineprotocol.Tag{}
+	for {
+		l = strings.TrimLeft(l, " \t")
+		if strings.HasPrefix(l, "}") {
+			return labels, l[1:], nil
+		}
+		i := strings.Index(l, "=")
+		if i <= 0 {
+			return nil, "", fmt.Errorf("invalid labels")
+		}
+		key := strings.TrimSpace(l[:i])
+		l = strings.TrimLeft(l[i+1:], " \t")
+		if !strings.HasPrefix(l, "\"") {
+			return nil, "", fmt.Errorf("unquoted value of label '%v'", key)
+		}
+		var value strings.Builder
+		closed := false
+		for i = 1; i < len(l) && !closed; i++ {
+			switch {
+			case l[i] == '"':
+				closed = true
+			case l[i] == '\\' && i+1 < len(l):
+				i++
+				switch l[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(l[i])
+				}
+			default:
+				value.WriteByte(l[i])
+			}
+		}
+		if !closed {
+			return nil, "", fmt.Errorf("unterminated value of label '%v'", key)
+		}
+		if value.Len() > 0 {
+			labels = append(labels, lineprotocol.Tag{Key: key, Value: value.String()})
+		}
+		l = strings.TrimLeft(l[i:], " \t")
+		l = strings.TrimPrefix(l, ",")
+	}
+}
@@ -0,0 +1,104 @@
+package convert
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const promMetrics = `# HELP http_requests_total The total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+http_requests_total{method="post",code="400"}    3 1395066363000
+
+# A comment
+go_goroutines 12
+# TYPE temperature gauge
+temperature{room="living \"room\"",floor="1"} 21.5
+temperature{room="cellar"} NaN
+
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{le="0.05"} 24054
+http_request_duration_seconds_bucket{le="0.1"} 33444
+http_request_duration_seconds_bucket{le="+Inf"} 144320
+http_request_duration_seconds_sum 53423
+http_request_duration_seconds_count 144320
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5",service="a"} 4773
+rpc_duration_seconds{quantile="0.99",service="a"} 76656
+rpc_duration_seconds_sum{service="a"} 1.7560473e+07
+rpc_duration_seconds_count{service="a"} 2693
+`
+
+func TestPrometheusConvert(t *testing.T) {
+	var tcs = []struct {
+		tcID    string
+		inConv  Prometheus
+		inData  string
+		expData string
+	}{
+		{
+			"perMetric",
+			Prometheus{},
+			promMetrics,
+			"http_requests_total,code=200,method=post counter=1027 1395066363000000000\n" +
+				"http_requests_total,code=400,method=post counter=3 1395066363000000000\n" +
+				"go_goroutines value=12\n" +
+				"temperature,floor=1,room=living\\ \"room\" gauge=21.5\n" +
+				"http_request_duration_seconds 0.05=24054,0.1=33444,+Inf=144320,sum=53423,count=144320\n" +
+				"rpc_duration_seconds,service=a 0.5=4773,0.99=76656,sum=1.7560473e+07,count=2693\n",
+		},
+		{
+			"singleMeasurement",
+			Prometheus{Measurement: "prometheus", Precision: time.Second},
+			promMetrics,
+			"prometheus,code=200,method=post http_requests_total=1027 1395066363\n" +
+				"prometheus,code=400,method=post http_requests_total=3 1395066363\n" +
+				"prometheus go_goroutines=12\n" +
+				"prometheus,floor=1,room=living\\ \"room\" temperature=21.5\n" +
+				"prometheus http_request_duration_seconds_bucket_0.05=24054,http_request_duration_seconds_bucket_0.1=33444,http_request_duration_seconds_bucket_+Inf=144320,http_request_duration_seconds_sum=53423,http_request_duration_seconds_count=144320\n" +
+				"prometheus,service=a rpc_duration_seconds_quantile_0.5=4773,rpc_duration_seconds_quantile_0.99=76656,rpc_duration_seconds_sum=1.7560473e+07,rpc_duration_seconds_count=2693\n",
+		},
+		{
+			"openMetrics",
+			Prometheus{OpenMetrics: true, Precision: time.Millisecond},
+			"# TYPE jobs counter\n# UNIT jobs jobs\njobs_total{queue=\"q\"} 5 1395066363.5 # {trace_id=\"abc\"} 1 1395066363.1\njobs_created{queue=\"q\"} 1395066000\n# EOF\nignored 1\n",
+			"jobs,queue=q counter=5 1395066363500\n",
+		},
+		{"empty", Prometheus{}, "", ""},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			var out bytes.Buffer
+			assert.Nil(t, tc.inConv.Convert(strings.NewReader(tc.inData), &out))
+			assert.Equal(t, tc.expData, out.String())
+		})
+	}
+}
+
+func TestPrometheusConvertErrors(t *testing.T) {
+	var tcs = []struct {
+		tcID   string
+		inData string
+		expErr string
+	}{
+		{"noValue", "m\n", "line 1: no value"},
+		{"invalidValue", "# TYPE m gauge\nm{a=\"b\"} x\n", "line 2: invalid value 'x'"},
+		{"invalidTimestamp", "m 1 1.5\n", "line 1: invalid Unix timestamp '1.5'"},
+		{"tooManyValues", "m 1 2 3\n", "line 1: invalid sample '1 2 3'"},
+		{"unquotedLabel", "m{a=b} 1\n", "line 1: unquoted value of label 'a'"},
+		{"unterminatedLabel", "m{a=\"b} 1\n", "line 1: unterminated value of label 'a'"},
+		{"invalidLabels", "m{a} 1\n", "line 1: invalid labels"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			err := Prometheus{}.Convert(strings.NewReader(tc.inData), &bytes.Buffer{})
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), tc.expErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package pusher
+
+import (
+	"fmt"
+	"io"
+)
+
+// Converter converts data in another format (CSV, JSON, ...) into line
+// protocol. See the convert package for the available converters.
+type Converter interface {
+	// Convert reads the data from r and writes the corresponding line
+	// protocol to w
+	Convert(r io.Reader, w io.Writer) error
+}
+
+// OptWithConverter is an optional function that specifies the converter
+// turning the pushed data into line protocol, on the fly. Line numbers
+// (dead letter file, partial writes, checkpoints) then refer to the
+// converted data.
+func OptWithConverter(c Converter) func(*Pusher) error {
+	return func(p *Pusher) error {
+		if c == nil {
+			return fmt.Errorf("no converter provided")
+		}
+		p.converter = c
+		return nil
+	}
+}
+
+// convert returns a reader providing the line protocol converted from the
+// data read from r, and a function stopping the conversion and waiting for
+// it to be over
+func (p *Pusher) convert(r io.Reader) (io.Reader, func()) {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := p.converter.Convert(r, pw)
+		if err != nil {
+			err = fmt.Errorf("error when converting data: %v", err)
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, func() {
+		pr.Close()
+		<-done
+	}
+}
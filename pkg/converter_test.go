@@ -0,0 +1,75 @@
+package pusher
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// wordsConverter converts lines of words into points of the measurement w
+type wordsConverter struct{}
+
+func (wordsConverter) Convert(r io.Reader, w io.Writer) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if s.Text() == "fail" {
+			return fmt.Errorf("conversion failure")
+		}
+		if _, err := fmt.Fprintf(w, "w value=\"%v\"\n", s.Text()); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+func TestOptWithConverter(t *testing.T) {
+	p := Pusher{}
+	assert.Nil(t, OptWithConverter(wordsConverter{})(&p))
+	assert.Equal(t, wordsConverter{}, p.converter)
+	assert.NotNil(t, OptWithConverter(nil)(&p))
+}
+
+func TestPushConverter(t *testing.T) {
+	var tcs = []struct {
+		tcID      string
+		inData    string
+		expData   string
+		expPoints int
+		expErr    bool
+	}{
+		{"nominal", "a\nb\nc\n", "w value=\"a\"\nw value=\"b\"\nw value=\"c\"\n", 3, false},
+		{"failure", "a\nb\nfail\nc\n", "", 0, true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			var received []string
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				c, err := ioutil.ReadAll(req.Body)
+				assert.Nil(t, err)
+				received = append(received, string(c))
+				rw.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			p, err := NewPusher(srv.URL, "d", OptWithConverter(wordsConverter{}), OptWithBatchSize(1))
+			assert.Nil(t, err)
+			rep, err := p.PushReader(context.Background(), strings.NewReader(tc.inData))
+			assert.Equal(t, tc.expErr, err != nil)
+			if tc.expErr {
+				assert.True(t, IsPusherError(err))
+				assert.Contains(t, err.Error(), "conversion failure")
+				return
+			}
+			assert.Equal(t, tc.expPoints, rep.Points)
+			assert.Equal(t, tc.expData, strings.Join(received, ""))
+		})
+	}
+}
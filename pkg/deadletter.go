@@ -0,0 +1,206 @@
+package pusher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OptWithDeadLetterFile is an optional function that specifies the file
+// where the lines rejected by InfluxDB are written (appended), each one
+// preceded by a comment giving the reason of the rejection.
+// When a batch is rejected (bad request or partial write), it is bisected
+// to isolate the rejected lines, the other lines are pushed and the push
+// goes on.
+func OptWithDeadLetterFile(path string) func(*Pusher) error {
+	return func(p *Pusher) error {
+		if path == "" {
+			return fmt.Errorf("no dead letter file provided")
+		}
+		p.deadLetter = &deadLetter{path: path}
+		return nil
+	}
+}
+
+// deadLetter appends rejected lines to a file
+type deadLetter struct {
+	path string
+	mu   sync.Mutex
+}
+
+// dataLine is a line of a batch
+type dataLine struct {
+	number  int
+	content []byte
+}
+
+func (d *deadLetter) write(name string, l dataLine, reason string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f, err := os.OpenFile(d.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("error when opening dead letter file '%v': %v", d.path, err)
+	}
+	location := fmt.Sprintf("line %v", l.number)
+	if name != "" {
+		location = fmt.Sprintf("%v:%v", name, l.number)
+	}
+	reason = strings.Replace(reason, "\n", " ", -1)
+	_, err = fmt.Fprintf(f, "# %v: %v\n%s\n", location, reason, l.content)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		return fmt.Errorf("error when writing dead letter file '%v': %v", d.path, err)
+	}
+	return nil
+}
+
+// isolation is the result of the isolation of the rejected lines of a
+// batch
+type isolation struct {
+	accepted int
+	rejected int
+	retries  int
+}
+
+// isRejection returns true if err means that InfluxDB rejected the data
+func isRejection(err error) bool {
+	if IsPartialWriteError(err) {
+		return true
+	}
+	var pe PushError
+	if errors.As(err, &pe) {
+		return pe.StatusCode == http.StatusBadRequest || pe.StatusCode == http.StatusUnprocessableEntity
+	}
+	return false
+}
+
+// rejectionReason returns the reason why a single line has been rejected
+func rejectionReason(err error) string {
+	var pwe *PartialWriteError
+	if errors.As(err, &pwe) && len(pwe.Rejected) > 0 {
+		return pwe.Rejected[0].Reason
+	}
+	var pe PushError
+	if errors.As(err, &pe) && pe.Message != "" {
+		return pe.Message
+	}
+	return err.Error()
+}
+
+// isolateRejected writes the lines of a rejected batch to the dead letter
+// file, and pushes the other ones
+func (p *Pusher) isolateRejected(ctx context.Context, client *http.Client, uStr string, name string, bt *batch, err error) (isolation, error) {
+	lines, splitErr := splitLines(bt)
+	if splitErr != nil {
+		return isolation{}, newError(errTypePusher, fmt.Errorf("error when splitting batch #%v: %v", bt.index, splitErr))
+	}
+
+	// InfluxDB confirmed a partial write and reported every rejected line:
+	// the other ones have been written. Otherwise (whole batch rejected,
+	// even if the bad lines are listed), nothing has been written.
+	var pwe *PartialWriteError
+	if errors.As(err, &pwe) && bt.points > pwe.Accepted && len(pwe.Rejected) == bt.points-pwe.Accepted {
+		byNumber := map[int]dataLine{}
+		for _, l := range lines {
+			byNumber[l.number] = l
+		}
+		identified := true
+		for _, rl := range pwe.Rejected {
+			if _, found := byNumber[rl.Line]; !found {
+				identified = false
+			}
+		}
+		if identified {
+			for _, rl := range pwe.Rejected {
+				if err := p.deadLetter.write(name, byNumber[rl.Line], rl.Reason); err != nil {
+					return isolation{}, newError(errTypePusher, err)
+				}
+			}
+			return isolation{accepted: pwe.Accepted, rejected: len(pwe.Rejected)}, nil
+		}
+	}
+
+	return p.bisect(ctx, client, uStr, name, bt.index, lines, err)
+}
+
+// bisect isolates the rejected lines of lines, that have been rejected
+// with err, by pushing each half of them until single lines are rejected
+func (p *Pusher) bisect(ctx context.Context, client *http.Client, uStr string, name string, index int, lines []dataLine, err error) (isolation, error) {
+	iso := isolation{}
+	if len(lines) == 1 {
+		logrus.Warnf("Line %v rejected: %v", lines[0].number, rejectionReason(err))
+		if err := p.deadLetter.write(name, lines[0], rejectionReason(err)); err != nil {
+			return iso, newError(errTypePusher, err)
+		}
+		iso.rejected = 1
+		return iso, nil
+	}
+
+	mid := len(lines) / 2
+	for _, half := range [][]dataLine{lines[:mid], lines[mid:]} {
+		retries, err := p.sendWithRetries(ctx, client, uStr, linesBatch(index, half))
+		iso.retries += retries
+		if err == nil {
+			iso.accepted += len(half)
+			continue
+		}
+		if !isRejection(err) {
+			return iso, err
+		}
+		sub, err := p.bisect(ctx, client, uStr, name, index, half, err)
+		iso.accepted += sub.accepted
+		iso.rejected += sub.rejected
+		iso.retries += sub.retries
+		if err != nil {
+			return iso, err
+		}
+	}
+	return iso, nil
+}
+
+// splitLines returns the points of a batch, with their line number
+func splitLines(bt *batch) ([]dataLine, error) {
+	r, err := bt.reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	lines := []dataLine{}
+	br := bufio.NewReader(r)
+	for n := bt.firstLine; ; n++ {
+		l, err := br.ReadBytes('\n')
+		if isPoint(l) {
+			lines = append(lines, dataLine{number: n, content: bytes.TrimRight(l, "\r\n")})
+		}
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// linesBatch builds a batch made of lines
+func linesBatch(index int, lines []dataLine) *batch {
+	bt := batch{index: index, points: len(lines)}
+	if len(lines) > 0 {
+		bt.firstLine = lines[0].number
+	}
+	for _, l := range lines {
+		bt.data = append(bt.data, l.content...)
+		bt.data = append(bt.data, '\n')
+	}
+	return &bt
+}
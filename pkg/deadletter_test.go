@@ -0,0 +1,174 @@
+package pusher
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptWithDeadLetterFile(t *testing.T) {
+	p := Pusher{}
+	assert.Nil(t, OptWithDeadLetterFile("dl.txt")(&p))
+	assert.Equal(t, "dl.txt", p.deadLetter.path)
+	assert.NotNil(t, OptWithDeadLetterFile("")(&p))
+}
+
+func TestSplitLines(t *testing.T) {
+	bt := &batch{firstLine: 5, data: []byte("# c\na v=1\n\nb v=2\r\nc v=3")}
+	lines, err := splitLines(bt)
+	assert.Nil(t, err)
+	assert.Equal(t, []dataLine{
+		{number: 6, content: []byte("a v=1")},
+		{number: 8, content: []byte("b v=2")},
+		{number: 9, content: []byte("c v=3")},
+	}, lines)
+
+	sub := linesBatch(3, lines[1:])
+	assert.Equal(t, 3, sub.index)
+	assert.Equal(t, 8, sub.firstLine)
+	assert.Equal(t, 2, sub.points)
+	assert.Equal(t, "b v=2\nc v=3\n", string(sub.data))
+}
+
+func TestPushDeadLetterBisect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	dl := filepath.Join(dir, "deadLetter.txt")
+
+	var mu sync.Mutex
+	written := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		c, err := ioutil.ReadAll(req.Body)
+		assert.Nil(t, err)
+		if strings.Contains(string(c), "bad") {
+			rw.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(rw, `{"error":"invalid field format"}`)
+			return
+		}
+		mu.Lock()
+		written = append(written, strings.Split(strings.TrimSpace(string(c)), "\n")...)
+		mu.Unlock()
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	data := "m v=1\nm v=2\nm bad\nm v=4\nm v=5\nm v=6\nm v=7\nm bad2\nm v=9\nm v=10\nm v=11\n"
+	p, err := NewPusher(srv.URL, "d", OptWithDeadLetterFile(dl), OptWithBatchSize(5))
+	assert.Nil(t, err)
+	rep, err := p.PushReader(context.Background(), strings.NewReader(data))
+	assert.Nil(t, err)
+	assert.Equal(t, 9, rep.Points)
+	assert.Equal(t, 2, rep.DeadLettered)
+	assert.Equal(t, 3, rep.Batches)
+	assert.Empty(t, rep.Failures)
+	assert.ElementsMatch(t, []string{"m v=1", "m v=2", "m v=4", "m v=5", "m v=6", "m v=7", "m v=9", "m v=10", "m v=11"}, written)
+
+	c, err := ioutil.ReadFile(dl)
+	assert.Nil(t, err)
+	assert.Equal(t, "# line 3: invalid field format\nm bad\n# line 8: invalid field format\nm bad2\n", string(c))
+}
+
+func TestPushDeadLetterPartialWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	dl := filepath.Join(dir, "deadLetter.txt")
+
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		rw.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(rw, `{"error":"partial write: unable to parse 'm v=': missing field value dropped=0"}`)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d", OptWithDeadLetterFile(dl))
+	assert.Nil(t, err)
+	rep, err := p.PushReader(context.Background(), strings.NewReader("m v=1\nm v=\nm v=3\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, 2, rep.Points)
+	assert.Equal(t, 1, rep.DeadLettered)
+
+	c, err := ioutil.ReadFile(dl)
+	assert.Nil(t, err)
+	assert.Equal(t, "# line 2: missing field value\nm v=\n", string(c))
+}
+
+func TestPushDeadLetterWholeBatchRejected(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	dl := filepath.Join(dir, "deadLetter.txt")
+
+	var mu sync.Mutex
+	written := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		c, err := ioutil.ReadAll(req.Body)
+		assert.Nil(t, err)
+		lines := strings.Split(strings.TrimSpace(string(c)), "\n")
+		errs := []string{}
+		for i, l := range lines {
+			if strings.Contains(l, "bad") {
+				errs = append(errs, fmt.Sprintf("line %v: invalid field format", i+1))
+			}
+		}
+		if len(errs) > 0 {
+			// InfluxDB 2.x rejects the whole batch, listing the bad lines
+			rw.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(rw, `{"code":"invalid","message":"failed to parse line protocol:\nerrors encountered on line(s):\n%v"}`, strings.Join(errs, "\\n"))
+			return
+		}
+		mu.Lock()
+		written = append(written, lines...)
+		mu.Unlock()
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "", OptWithOrg("o"), OptWithBucket("b"), OptWithDeadLetterFile(dl))
+	assert.Nil(t, err)
+	rep, err := p.PushReader(context.Background(), strings.NewReader("m v=1\nm bad\nm v=3\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, rep.Points)
+	assert.Equal(t, 1, rep.DeadLettered)
+	assert.ElementsMatch(t, []string{"m v=1", "m v=3"}, written)
+
+	c, err := ioutil.ReadFile(dl)
+	assert.Nil(t, err)
+	assert.Equal(t, "# line 2: failed to parse line protocol: errors encountered on line(s): line 1: invalid field format\nm bad\n", string(c))
+}
+
+func TestPushDeadLetterServerProblem(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		if requests == 1 {
+			rw.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(rw, `{"error":"invalid field format"}`)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d", OptWithDeadLetterFile(filepath.Join(dir, "dl.txt")))
+	assert.Nil(t, err)
+	rep, err := p.PushReader(context.Background(), strings.NewReader("m v=1\nm v=2\n"))
+	assert.True(t, IsServerProblemError(err))
+	assert.Len(t, rep.Failures, 1)
+}
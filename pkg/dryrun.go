@@ -0,0 +1,105 @@
+package pusher
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/barasher/influxdb-pusher/pkg/lineprotocol"
+	"github.com/sirupsen/logrus"
+)
+
+// OptWithDryRun is an optional function that enables the dry run mode:
+// the data is read and split into batches but no write request is sent.
+// The report tells what would have been sent, including the time range
+// covered by the points (Report.MinTime and Report.MaxTime). Checkpoints
+// are neither used nor saved.
+func OptWithDryRun() func(*Pusher) error {
+	return func(p *Pusher) error {
+		p.dryRun = true
+		return nil
+	}
+}
+
+// RedactedURL returns the URL of the write requests, credentials redacted
+func (p *Pusher) RedactedURL() (string, error) {
+	uStr, err := p.writeURL()
+	if err != nil {
+		return "", err
+	}
+	return redactURL(uStr), nil
+}
+
+const redacted = "xxxxx"
+
+// redactURL replaces the password of the URL (user info or p query
+// parameter) with a placeholder
+func redactURL(uStr string) string {
+	u, err := url.Parse(uStr)
+	if err != nil {
+		return uStr
+	}
+	q := u.Query()
+	if q.Get("p") != "" {
+		q.Set("p", redacted)
+		u.RawQuery = q.Encode()
+	}
+	return u.Redacted()
+}
+
+// precisionUnits maps the precision parameter values (1.x and 2.x) to the
+// unit of the timestamps
+var precisionUnits = map[string]time.Duration{
+	"":   time.Nanosecond,
+	"ns": time.Nanosecond,
+	"u":  time.Microsecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// timeRange returns the oldest and most recent timestamps of the points of
+// the batch bt (zero times if no point has a timestamp). Lines that can't
+// be parsed are ignored.
+func (p *Pusher) timeRange(bt *batch) (time.Time, time.Time, error) {
+	r, err := bt.reader()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	defer r.Close()
+	count := bt.file != nil && !bt.counted
+	unit := precisionUnits[p.precision]
+	var minTime, maxTime time.Time
+	s := bufio.NewReader(r)
+	for {
+		l, err := s.ReadBytes('\n')
+		if count {
+			bt.tally(l)
+		}
+		if len(l) > 0 && !lineprotocol.IsComment(l) {
+			pt, perr := lineprotocol.ParseLine(l)
+			if perr != nil {
+				logrus.Debugf("Line ignored: %v", perr)
+			} else if pt.HasTime {
+				t := time.Unix(0, pt.Time*int64(unit)).UTC()
+				if minTime.IsZero() || t.Before(minTime) {
+					minTime = t
+				}
+				if maxTime.IsZero() || t.After(maxTime) {
+					maxTime = t
+				}
+			}
+		}
+		if err == io.EOF {
+			bt.counted = true
+			return minTime, maxTime, nil
+		}
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("error when reading batch: %v", err)
+		}
+	}
+}
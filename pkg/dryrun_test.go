@@ -0,0 +1,88 @@
+package pusher
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptWithDryRun(t *testing.T) {
+	p := Pusher{}
+	assert.Nil(t, OptWithDryRun()(&p))
+	assert.True(t, p.dryRun)
+}
+
+func TestRedactURL(t *testing.T) {
+	var tcs = []struct {
+		tcID   string
+		inURL  string
+		expURL string
+	}{
+		{"noCredentials", "http://h:8086/write?db=d", "http://h:8086/write?db=d"},
+		{"queryParams", "http://h:8086/write?db=d&p=secret&u=user", "http://h:8086/write?db=d&p=xxxxx&u=user"},
+		{"userInfo", "http://user:secret@h:8086/write?db=d", "http://user:xxxxx@h:8086/write?db=d"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			assert.Equal(t, tc.expURL, redactURL(tc.inURL))
+		})
+	}
+}
+
+func TestRedactedURL(t *testing.T) {
+	p, err := NewPusher("http://h:8086", "d", OptWithUserPass("user", "secret"))
+	assert.Nil(t, err)
+	u, err := p.RedactedURL()
+	assert.Nil(t, err)
+	assert.NotContains(t, u, "secret")
+	assert.Contains(t, u, "db=d")
+}
+
+func TestPushDryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	state := filepath.Join(dir, "state.json")
+
+	var tcs = []struct {
+		tcID       string
+		inFile     string
+		inOpts     []func(*Pusher) error
+		expBatches int
+	}{
+		{"batches", "../testdata/sampleData.txt", []func(*Pusher) error{OptWithBatchSize(500)}, 4},
+		{"compressed", "../testdata/sampleData.txt.bz2", []func(*Pusher) error{}, 1},
+		{"gzipPassthrough", "../testdata/sampleData.txt.gz", []func(*Pusher) error{OptWithGzip()}, 1},
+		{"checkpoint", "../testdata/sampleData.txt", []func(*Pusher) error{OptWithCheckpointFile(state)}, 1},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			requests := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				requests++
+				rw.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			opts := append([]func(*Pusher) error{OptWithDryRun(), OptWithPrecision(PrecisionSecond)}, tc.inOpts...)
+			p, err := NewPusher(srv.URL, "d", opts...)
+			assert.Nil(t, err)
+			rep, err := p.Push(tc.inFile)
+			assert.Nil(t, err)
+			assert.Equal(t, 0, requests)
+			assert.Equal(t, tc.expBatches, rep.Batches)
+			assert.Equal(t, 2000, rep.Points)
+			assert.Equal(t, int64(201302), rep.Bytes)
+			assert.Equal(t, time.Unix(1439856000, 0).UTC(), rep.MinTime)
+			assert.Equal(t, time.Unix(1440575640, 0).UTC(), rep.MaxTime)
+			_, err = os.Stat(state)
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
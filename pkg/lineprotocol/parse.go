@@ -0,0 +1,309 @@
+package lineprotocol
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseError is the error returned when a line can't be parsed
+type ParseError struct {
+	// Line is the line number (starting from 1), 0 if unknown
+	Line int
+	// Column is the column (starting from 1, in bytes) where the problem
+	// has been detected
+	Column int
+	// Msg describes the problem
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("column %v: %v", e.Column, e.Msg)
+	}
+	return fmt.Sprintf("line %v, column %v: %v", e.Line, e.Column, e.Msg)
+}
+
+// Parser reads points from line protocol data
+type Parser struct {
+	r              *bufio.Reader
+	line           int
+	unknownEscapes []int
+}
+
+// NewParser returns a parser reading the line protocol data from r
+func NewParser(r io.Reader) *Parser {
+	return &Parser{r: bufio.NewReader(r)}
+}
+
+// Next returns the next point, skipping empty lines and comments, or
+// io.EOF if there is no more point. If a line can't be parsed, a
+// *ParseError is returned and the following lines can still be read.
+func (p *Parser) Next() (Point, error) {
+	for {
+		l, err := p.r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return Point{}, err
+		}
+		if len(l) == 0 {
+			return Point{}, io.EOF
+		}
+		p.line++
+		if IsComment(l) {
+			continue
+		}
+		pt, escapes, perr := parseLine(l)
+		if perr != nil {
+			perr.(*ParseError).Line = p.line
+			return Point{}, perr
+		}
+		p.unknownEscapes = escapes
+		return pt, nil
+	}
+}
+
+// Line returns the number (starting from 1) of the last read line
+func (p *Parser) Line() int {
+	return p.line
+}
+
+// IsComment returns true if the line is empty (or blank) or is a comment
+func IsComment(line []byte) bool {
+	l := bytes.TrimSpace(line)
+	return len(l) == 0 || l[0] == '#'
+}
+
+// ParseLine parses a single line (the trailing newline, if any, is
+// ignored). The returned error, if any, is a *ParseError.
+// A backslash that doesn't escape a character requiring it is kept as is.
+func ParseLine(line []byte) (Point, error) {
+	pt, _, err := parseLine(line)
+	return pt, err
+}
+
+// parseLine parses a single line and also returns the columns of the
+// backslashes that don't escape anything
+func parseLine(line []byte) (Point, []int, error) {
+	s := scanner{buf: bytes.TrimRight(line, "\r\n")}
+	pt, err := s.parse()
+	if err != nil {
+		return Point{}, nil, err
+	}
+	return pt, s.unknownEscapes, nil
+}
+
+func (s *scanner) parse() (Point, error) {
+	line := s.buf
+	var pt Point
+
+	start := s.pos
+	m := s.readToken(" ,", " ,")
+	if m == "" {
+		return Point{}, s.errorAt(start, "missing measurement")
+	}
+	pt.Measurement = m
+
+	for s.peek() == ',' {
+		s.pos++
+		start = s.pos
+		k := s.readToken(",= ", ",= ")
+		if k == "" {
+			return Point{}, s.errorAt(start, "missing tag key")
+		}
+		if s.peek() != '=' {
+			return Point{}, s.errorf("missing '=' after tag key '%v'", k)
+		}
+		s.pos++
+		start = s.pos
+		v := s.readToken(", ", ",= ")
+		if v == "" {
+			return Point{}, s.errorAt(start, fmt.Sprintf("missing value of tag '%v'", k))
+		}
+		pt.Tags = append(pt.Tags, Tag{Key: k, Value: v})
+	}
+
+	if !s.skipSpaces() {
+		return Point{}, s.errorf("missing fields")
+	}
+	for {
+		start = s.pos
+		k := s.readToken(",= ", ",= ")
+		if k == "" {
+			return Point{}, s.errorAt(start, "missing field key")
+		}
+		if s.peek() != '=' {
+			return Point{}, s.errorf("missing '=' after field key '%v'", k)
+		}
+		s.pos++
+		v, err := s.readValue(k)
+		if err != nil {
+			return Point{}, err
+		}
+		pt.Fields = append(pt.Fields, Field{Key: k, Value: v})
+		if s.peek() != ',' {
+			break
+		}
+		s.pos++
+	}
+
+	if !s.skipSpaces() {
+		if !s.eol() {
+			return Point{}, s.errorf("unexpected character '%c'", s.peek())
+		}
+		return pt, nil
+	}
+	start = s.pos
+	for !s.eol() && s.peek() != ' ' {
+		s.pos++
+	}
+	ts := string(line[start:s.pos])
+	t, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return Point{}, s.errorAt(start, fmt.Sprintf("invalid timestamp '%v'", ts))
+	}
+	pt.Time, pt.HasTime = t, true
+	if s.skipSpaces(); !s.eol() {
+		return Point{}, s.errorf("unexpected content after timestamp")
+	}
+	return pt, nil
+}
+
+// scanner reads the tokens of a line
+type scanner struct {
+	buf            []byte
+	pos            int
+	unknownEscapes []int
+}
+
+func (s *scanner) eol() bool {
+	return s.pos >= len(s.buf)
+}
+
+func (s *scanner) peek() byte {
+	if s.eol() {
+		return 0
+	}
+	return s.buf[s.pos]
+}
+
+// skipSpaces skips spaces and returns true if at least one has been
+// skipped and the end of the line hasn't been reached
+func (s *scanner) skipSpaces() bool {
+	start := s.pos
+	for s.peek() == ' ' {
+		s.pos++
+	}
+	return s.pos > start && !s.eol()
+}
+
+// readToken reads an unquoted token, up to one of the stop characters.
+// A backslash followed by one of the escaped characters is unescaped,
+// other backslashes are kept as is.
+func (s *scanner) readToken(stops string, escaped string) string {
+	var b []byte
+	for !s.eol() {
+		c := s.buf[s.pos]
+		if c == '\\' && s.pos+1 < len(s.buf) && strings.IndexByte(escaped, s.buf[s.pos+1]) >= 0 {
+			b = append(b, s.buf[s.pos+1])
+			s.pos += 2
+			continue
+		}
+		if strings.IndexByte(stops, c) >= 0 {
+			break
+		}
+		if c == '\\' {
+			s.unknownEscapes = append(s.unknownEscapes, s.pos+1)
+		}
+		b = append(b, c)
+		s.pos++
+	}
+	return string(b)
+}
+
+// readValue reads the value of the field k
+func (s *scanner) readValue(k string) (interface{}, error) {
+	start := s.pos
+	if s.peek() == '"' {
+		s.pos++
+		var b []byte
+		for {
+			if s.eol() {
+				return nil, s.errorAt(start, fmt.Sprintf("unterminated string value of field '%v'", k))
+			}
+			c := s.buf[s.pos]
+			if c == '\\' && s.pos+1 < len(s.buf) && (s.buf[s.pos+1] == '"' || s.buf[s.pos+1] == '\\') {
+				b = append(b, s.buf[s.pos+1])
+				s.pos += 2
+				continue
+			}
+			if c == '\\' {
+				s.unknownEscapes = append(s.unknownEscapes, s.pos+1)
+			}
+			s.pos++
+			if c == '"' {
+				return string(b), nil
+			}
+			b = append(b, c)
+		}
+	}
+
+	for !s.eol() && s.peek() != ',' && s.peek() != ' ' {
+		s.pos++
+	}
+	v := string(s.buf[start:s.pos])
+	if v == "" {
+		return nil, s.errorAt(start, fmt.Sprintf("missing value of field '%v'", k))
+	}
+	switch v {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+	invalid := func(t FieldType) error {
+		return s.errorAt(start, fmt.Sprintf("invalid %v value '%v' of field '%v'", FieldTypeToString[t], v, k))
+	}
+	switch v[len(v)-1] {
+	case 'i':
+		i, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+		if err != nil {
+			return nil, invalid(FieldInteger)
+		}
+		return i, nil
+	case 'u':
+		u, err := strconv.ParseUint(v[:len(v)-1], 10, 64)
+		if err != nil {
+			return nil, invalid(FieldUnsigned)
+		}
+		return u, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || !isNumber(v) {
+		return nil, invalid(FieldFloat)
+	}
+	return f, nil
+}
+
+func (s *scanner) errorAt(pos int, msg string) error {
+	return &ParseError{Column: pos + 1, Msg: msg}
+}
+
+func (s *scanner) errorf(format string, args ...interface{}) error {
+	return s.errorAt(s.pos, fmt.Sprintf(format, args...))
+}
+
+// isNumber returns true if v only holds the characters of a decimal
+// number, excluding the hexadecimal, infinity and NaN notations that
+// strconv accepts
+func isNumber(v string) bool {
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if (c < '0' || c > '9') && c != '.' && c != '-' && c != '+' && c != 'e' && c != 'E' {
+			return false
+		}
+	}
+	return true
+}
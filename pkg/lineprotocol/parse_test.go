@@ -0,0 +1,137 @@
+package lineprotocol
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLine(t *testing.T) {
+	var tcs = []struct {
+		tcID     string
+		inLine   string
+		expPoint Point
+	}{
+		{"minimal", "m v=1", Point{Measurement: "m", Fields: []Field{{"v", 1.0}}}},
+		{"timestamp", "m v=1 1439856000\n", Point{Measurement: "m", Fields: []Field{{"v", 1.0}}, Time: 1439856000, HasTime: true}},
+		{"negativeTimestamp", "m v=1 -10", Point{Measurement: "m", Fields: []Field{{"v", 1.0}}, Time: -10, HasTime: true}},
+		{"crlf", "m v=1 10\r\n", Point{Measurement: "m", Fields: []Field{{"v", 1.0}}, Time: 10, HasTime: true}},
+		{"tags", "m,a=1,b=2 v=1", Point{Measurement: "m", Tags: []Tag{{"a", "1"}, {"b", "2"}}, Fields: []Field{{"v", 1.0}}}},
+		{"types", `m f=-1.5e3,i=-3i,u=3u,s="a b",b=true,bf=F`, Point{Measurement: "m", Fields: []Field{
+			{"f", -1500.0}, {"i", int64(-3)}, {"u", uint64(3)}, {"s", "a b"}, {"b", true}, {"bf", false},
+		}}},
+		{"escapedMeasurement", `m\,x\ y v=1`, Point{Measurement: "m,x y", Fields: []Field{{"v", 1.0}}}},
+		{"escapedKeys", `m,t\=k=v\,w\ x l\ d=1`, Point{Measurement: "m", Tags: []Tag{{"t=k", "v,w x"}}, Fields: []Field{{"l d", 1.0}}}},
+		{"escapedString", `m s="a \"b\" c\\d, e=f"`, Point{Measurement: "m", Fields: []Field{{"s", `a "b" c\d, e=f`}}}},
+		{"unknownEscape", `m\x v=1`, Point{Measurement: `m\x`, Fields: []Field{{"v", 1.0}}}},
+		{"multipleSpaces", "m  v=1  10", Point{Measurement: "m", Fields: []Field{{"v", 1.0}}, Time: 10, HasTime: true}},
+		{"sample", `h2o_feet,location=coyote_creek water_level=8.120,level\ description="between 6 and 9 feet" 1439856000`, Point{
+			Measurement: "h2o_feet",
+			Tags:        []Tag{{"location", "coyote_creek"}},
+			Fields:      []Field{{"water_level", 8.12}, {"level description", "between 6 and 9 feet"}},
+			Time:        1439856000,
+			HasTime:     true,
+		}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			pt, err := ParseLine([]byte(tc.inLine))
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expPoint, pt)
+		})
+	}
+}
+
+func TestParseLineErrors(t *testing.T) {
+	var tcs = []struct {
+		tcID      string
+		inLine    string
+		expColumn int
+		expMsg    string
+	}{
+		{"empty", "", 1, "missing measurement"},
+		{"noMeasurement", ",a=b v=1", 1, "missing measurement"},
+		{"noFields", "m", 2, "missing fields"},
+		{"noFieldsWithTags", "m,a=b ", 7, "missing fields"},
+		{"noTagKey", "m,=b v=1", 3, "missing tag key"},
+		{"noTagEqual", "m,a v=1", 4, "missing '=' after tag key 'a'"},
+		{"noTagValue", "m,a= v=1", 5, "missing value of tag 'a'"},
+		{"noFieldKey", "m =1", 3, "missing field key"},
+		{"noFieldEqual", "m v", 4, "missing '=' after field key 'v'"},
+		{"noFieldValue", "m v=", 5, "missing value of field 'v'"},
+		{"trailingComma", "m v=1,", 7, "missing field key"},
+		{"invalidFloat", "m v=abc", 5, "invalid float value 'abc' of field 'v'"},
+		{"nan", "m v=NaN", 5, "invalid float value 'NaN' of field 'v'"},
+		{"invalidInteger", "m v=1.5i", 5, "invalid integer value '1.5i' of field 'v'"},
+		{"integerOverflow", "m v=9223372036854775808i", 5, "invalid integer value '9223372036854775808i' of field 'v'"},
+		{"invalidUnsigned", "m v=-1u", 5, "invalid unsigned value '-1u' of field 'v'"},
+		{"unterminatedString", `m v="abc`, 5, "unterminated string value of field 'v'"},
+		{"unexpected", `m v="a"b`, 8, "unexpected character 'b'"},
+		{"invalidTimestamp", "m v=1 abc", 7, "invalid timestamp 'abc'"},
+		{"afterTimestamp", "m v=1 10 x", 10, "unexpected content after timestamp"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			_, err := ParseLine([]byte(tc.inLine))
+			pe, ok := err.(*ParseError)
+			if assert.True(t, ok) {
+				assert.Equal(t, 0, pe.Line)
+				assert.Equal(t, tc.expColumn, pe.Column)
+				assert.Equal(t, tc.expMsg, pe.Msg)
+			}
+		})
+	}
+}
+
+func TestParser(t *testing.T) {
+	p := NewParser(strings.NewReader("# comment\nm v=1\n\nm v=\nm v=2"))
+
+	pt, err := p.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, p.Line())
+	assert.Equal(t, 1.0, pt.Fields[0].Value)
+
+	_, err = p.Next()
+	assert.Equal(t, &ParseError{Line: 4, Column: 5, Msg: "missing value of field 'v'"}, err)
+	assert.Equal(t, "line 4, column 5: missing value of field 'v'", err.Error())
+
+	pt, err = p.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, p.Line())
+	assert.Equal(t, 2.0, pt.Fields[0].Value)
+
+	_, err = p.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestParserSampleData(t *testing.T) {
+	f, err := os.Open("../../testdata/sampleData.txt")
+	assert.Nil(t, err)
+	defer f.Close()
+
+	p := NewParser(f)
+	points := 0
+	for {
+		pt, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if !assert.Nil(t, err) {
+			return
+		}
+		points++
+		assert.Equal(t, "h2o_feet", pt.Measurement)
+		assert.Equal(t, "level description", pt.Fields[1].Key)
+		assert.True(t, pt.HasTime)
+	}
+	assert.Equal(t, 2000, points)
+}
+
+func TestIsComment(t *testing.T) {
+	assert.True(t, IsComment([]byte("# c\n")))
+	assert.True(t, IsComment([]byte("  \n")))
+	assert.False(t, IsComment([]byte("m v=1\n")))
+}
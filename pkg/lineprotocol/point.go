@@ -0,0 +1,170 @@
+/*
+Package lineprotocol parses and encodes InfluxDB line protocol.
+*/
+package lineprotocol
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// FieldType is a type referring to the types of the field values
+type FieldType int
+
+const (
+	// FieldFloat is a constant referring to float fields (1.5)
+	FieldFloat FieldType = iota
+	// FieldInteger is a constant referring to integer fields (1i)
+	FieldInteger
+	// FieldUnsigned is a constant referring to unsigned integer fields (1u)
+	FieldUnsigned
+	// FieldString is a constant referring to string fields ("a")
+	FieldString
+	// FieldBoolean is a constant referring to boolean fields (true)
+	FieldBoolean
+)
+
+// FieldTypeToString maps field types to their name
+var FieldTypeToString = map[FieldType]string{
+	FieldFloat:    "float",
+	FieldInteger:  "integer",
+	FieldUnsigned: "unsigned",
+	FieldString:   "string",
+	FieldBoolean:  "boolean",
+}
+
+// Tag is a tag of a point
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// Field is a field of a point
+type Field struct {
+	Key string
+	// Value is a float64, an int64, a uint64, a string or a bool
+	Value interface{}
+}
+
+// Type returns the type of the value of the field
+func (f Field) Type() FieldType {
+	switch f.Value.(type) {
+	case int64:
+		return FieldInteger
+	case uint64:
+		return FieldUnsigned
+	case string:
+		return FieldString
+	case bool:
+		return FieldBoolean
+	default:
+		return FieldFloat
+	}
+}
+
+// Point is a line protocol point
+type Point struct {
+	Measurement string
+	Tags        []Tag
+	Fields      []Field
+	// Time is the timestamp of the point, in the precision of the write
+	// request, meaningful only if HasTime is true
+	Time    int64
+	HasTime bool
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	stringEscaper      = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// Check returns an error if the point can't be represented in line protocol:
+// no measurement, no field, empty key or tag value, NaN or infinite float
+// value, value of an unsupported type
+func (p Point) Check() error {
+	if p.Measurement == "" {
+		return fmt.Errorf("no measurement")
+	}
+	for _, t := range p.Tags {
+		if t.Key == "" {
+			return fmt.Errorf("empty tag key")
+		}
+		if t.Value == "" {
+			return fmt.Errorf("empty value of tag '%v'", t.Key)
+		}
+	}
+	if len(p.Fields) == 0 {
+		return fmt.Errorf("no field")
+	}
+	for _, f := range p.Fields {
+		if f.Key == "" {
+			return fmt.Errorf("empty field key")
+		}
+		switch v := f.Value.(type) {
+		case int64, uint64, string, bool:
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return fmt.Errorf("value of field '%v' isn't a finite number (%v)", f.Key, v)
+			}
+		default:
+			return fmt.Errorf("unsupported type of field '%v' (%T)", f.Key, v)
+		}
+	}
+	return nil
+}
+
+// Append appends the line protocol representation of the point, without
+// trailing newline, to dst and returns the extended buffer. The point is
+// expected to be valid (see Check), otherwise the line is invalid.
+func (p Point) Append(dst []byte) []byte {
+	dst = append(dst, measurementEscaper.Replace(p.Measurement)...)
+	for _, t := range p.Tags {
+		dst = append(dst, ',')
+		dst = append(dst, keyEscaper.Replace(t.Key)...)
+		dst = append(dst, '=')
+		dst = append(dst, keyEscaper.Replace(t.Value)...)
+	}
+	for i, f := range p.Fields {
+		if i == 0 {
+			dst = append(dst, ' ')
+		} else {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, keyEscaper.Replace(f.Key)...)
+		dst = append(dst, '=')
+		dst = appendValue(dst, f.Value)
+	}
+	if p.HasTime {
+		dst = append(dst, ' ')
+		dst = strconv.AppendInt(dst, p.Time, 10)
+	}
+	return dst
+}
+
+// String returns the line protocol representation of the point, like
+// Append
+func (p Point) String() string {
+	return string(p.Append(nil))
+}
+
+func appendValue(dst []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case int64:
+		return append(strconv.AppendInt(dst, v, 10), 'i')
+	case uint64:
+		return append(strconv.AppendUint(dst, v, 10), 'u')
+	case string:
+		dst = append(dst, '"')
+		dst = append(dst, stringEscaper.Replace(v)...)
+		return append(dst, '"')
+	case bool:
+		return strconv.AppendBool(dst, v)
+	case float64:
+		return strconv.AppendFloat(dst, v, 'g', -1, 64)
+	default:
+		return dst
+	}
+}
@@ -0,0 +1,80 @@
+package lineprotocol
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldType(t *testing.T) {
+	var tcs = []struct {
+		tcID    string
+		inValue interface{}
+		expType FieldType
+	}{
+		{"float", 1.5, FieldFloat},
+		{"integer", int64(1), FieldInteger},
+		{"unsigned", uint64(1), FieldUnsigned},
+		{"string", "a", FieldString},
+		{"boolean", true, FieldBoolean},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			assert.Equal(t, tc.expType, Field{Key: "k", Value: tc.inValue}.Type())
+		})
+	}
+}
+
+func TestPointString(t *testing.T) {
+	var tcs = []struct {
+		tcID    string
+		inPoint Point
+		expLine string
+	}{
+		{"minimal", Point{Measurement: "m", Fields: []Field{{"v", 1.0}}}, "m v=1"},
+		{"timestamp", Point{Measurement: "m", Fields: []Field{{"v", 1.5}}, Time: 10, HasTime: true}, "m v=1.5 10"},
+		{"types", Point{Measurement: "m", Fields: []Field{
+			{"f", 1e21}, {"i", int64(-3)}, {"u", uint64(3)}, {"s", `a "b" c\d`}, {"b", false},
+		}}, `m f=1e+21,i=-3i,u=3u,s="a \"b\" c\\d",b=false`},
+		{"escaping", Point{Measurement: "m,x y=z", Tags: []Tag{{"t=k", "v,w x"}}, Fields: []Field{{"l d", 1.0}}}, `m\,x\ y=z,t\=k=v\,w\ x l\ d=1`},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			assert.Equal(t, tc.expLine, tc.inPoint.String())
+			pt, err := ParseLine([]byte(tc.expLine))
+			assert.Nil(t, err)
+			assert.Equal(t, tc.inPoint, pt)
+		})
+	}
+}
+
+func TestPointCheck(t *testing.T) {
+	var tcs = []struct {
+		tcID    string
+		inPoint Point
+		expErr  string
+	}{
+		{"valid", Point{Measurement: "m", Tags: []Tag{{"t", "a"}}, Fields: []Field{{"v", 1.0}, {"s", ""}}}, ""},
+		{"noMeasurement", Point{Fields: []Field{{"v", 1.0}}}, "no measurement"},
+		{"emptyTagKey", Point{Measurement: "m", Tags: []Tag{{"", "a"}}, Fields: []Field{{"v", 1.0}}}, "empty tag key"},
+		{"emptyTagValue", Point{Measurement: "m", Tags: []Tag{{"t", ""}}, Fields: []Field{{"v", 1.0}}}, "empty value of tag 't'"},
+		{"noField", Point{Measurement: "m"}, "no field"},
+		{"emptyFieldKey", Point{Measurement: "m", Fields: []Field{{"", 1.0}}}, "empty field key"},
+		{"nan", Point{Measurement: "m", Fields: []Field{{"v", math.NaN()}}}, "value of field 'v' isn't a finite number (NaN)"},
+		{"infinite", Point{Measurement: "m", Fields: []Field{{"v", math.Inf(-1)}}}, "value of field 'v' isn't a finite number (-Inf)"},
+		{"unsupportedType", Point{Measurement: "m", Fields: []Field{{"v", 1}}}, "unsupported type of field 'v' (int)"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			err := tc.inPoint.Check()
+			if tc.expErr == "" {
+				assert.Nil(t, err)
+				return
+			}
+			if assert.NotNil(t, err) {
+				assert.Equal(t, tc.expErr, err.Error())
+			}
+		})
+	}
+}
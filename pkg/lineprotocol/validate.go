@@ -0,0 +1,200 @@
+package lineprotocol
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProblemKind is a type referring to the kinds of problems found by
+// Validate
+type ProblemKind string
+
+const (
+	// ProblemSyntax is a line that can't be parsed
+	ProblemSyntax ProblemKind = "syntax"
+	// ProblemEscape is a backslash that doesn't escape anything, kept as is
+	// by InfluxDB
+	ProblemEscape ProblemKind = "escape"
+	// ProblemTypeConflict is a field whose type differs from the type it
+	// has on a previous line, for the same measurement
+	ProblemTypeConflict ProblemKind = "type conflict"
+	// ProblemTimestamp is a timestamp that is implausible with the
+	// precision of the write request
+	ProblemTimestamp ProblemKind = "timestamp"
+	// ProblemDuplicate is a point having the same series and timestamp as
+	// a previous one (InfluxDB keeps the last one), or a field repeated on
+	// a line
+	ProblemDuplicate ProblemKind = "duplicate"
+)
+
+// Problem is a problem found in line protocol data
+type Problem struct {
+	// Line is the line number (starting from 1)
+	Line int `json:"line"`
+	// Column is the column (starting from 1, in bytes), 0 if the problem
+	// concerns the whole line
+	Column int `json:"column,omitempty"`
+	// Kind is the kind of the problem
+	Kind ProblemKind `json:"kind"`
+	// Msg describes the problem
+	Msg string `json:"message"`
+}
+
+func (p Problem) String() string {
+	if p.Column == 0 {
+		return fmt.Sprintf("%v: %v: %v", p.Line, p.Kind, p.Msg)
+	}
+	return fmt.Sprintf("%v:%v: %v: %v", p.Line, p.Column, p.Kind, p.Msg)
+}
+
+// ValidationReport is the result of the validation of line protocol data
+type ValidationReport struct {
+	// Lines is the number of lines
+	Lines int `json:"lines"`
+	// Points is the number of points that have been parsed
+	Points int `json:"points"`
+	// Problems lists the problems, ordered by line
+	Problems []Problem `json:"problems"`
+}
+
+// plausibleTimes is the range in which the timestamps are expected to be
+var plausibleTimes = [2]time.Time{
+	time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC),
+	time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC),
+}
+
+// precisions lists the units timestamps can be expressed in, with their
+// InfluxDB name
+var precisions = []struct {
+	unit time.Duration
+	name string
+}{
+	{time.Nanosecond, "ns"},
+	{time.Microsecond, "u"},
+	{time.Millisecond, "ms"},
+	{time.Second, "s"},
+	{time.Minute, "m"},
+	{time.Hour, "h"},
+}
+
+// Validate checks every line of the line protocol data read from r:
+// syntax errors, backslashes escaping nothing, field type conflicts (for a
+// given measurement and field), timestamps that are implausible (before
+// 1971 or after 2200) with the precision unit (nanosecond if 0), and
+// duplicate points. The series and timestamps of every point are kept in
+// memory to detect duplicates.
+// An error is only returned if r can't be read.
+func Validate(r io.Reader, unit time.Duration) (ValidationReport, error) {
+	if unit == 0 {
+		unit = time.Nanosecond
+	}
+	rep := ValidationReport{Problems: []Problem{}}
+	fieldTypes := map[string]fieldSeen{}
+	points := map[string]int{}
+	p := NewParser(r)
+	for {
+		pt, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if pe, ok := err.(*ParseError); ok {
+			rep.Problems = append(rep.Problems, Problem{Line: pe.Line, Column: pe.Column, Kind: ProblemSyntax, Msg: pe.Msg})
+			continue
+		}
+		if err != nil {
+			return rep, err
+		}
+		rep.Points++
+		line := p.Line()
+		add := func(kind ProblemKind, format string, args ...interface{}) {
+			rep.Problems = append(rep.Problems, Problem{Line: line, Kind: kind, Msg: fmt.Sprintf(format, args...)})
+		}
+
+		for _, c := range p.unknownEscapes {
+			rep.Problems = append(rep.Problems, Problem{Line: line, Column: c, Kind: ProblemEscape, Msg: "backslash doesn't escape anything, it is kept as is"})
+		}
+
+		keys := map[string]bool{}
+		for _, f := range pt.Fields {
+			if keys[f.Key] {
+				add(ProblemDuplicate, "field '%v' is repeated", f.Key)
+			}
+			keys[f.Key] = true
+			k := pt.Measurement + "\x00" + f.Key
+			seen, found := fieldTypes[k]
+			if !found {
+				fieldTypes[k] = fieldSeen{t: f.Type(), line: line}
+				continue
+			}
+			if seen.t != f.Type() {
+				add(ProblemTypeConflict, "field '%v' of measurement '%v' is %v, it is %v at line %v", f.Key, pt.Measurement, FieldTypeToString[f.Type()], FieldTypeToString[seen.t], seen.line)
+			}
+		}
+
+		if !pt.HasTime {
+			continue
+		}
+		if !isPlausible(pt.Time, unit) {
+			msg := fmt.Sprintf("timestamp %v is implausible with precision '%v' (%v)", pt.Time, precisionName(unit), toTime(pt.Time, unit))
+			for _, pr := range precisions {
+				if isPlausible(pt.Time, pr.unit) {
+					msg += fmt.Sprintf(", precision '%v' would be", pr.name)
+					break
+				}
+			}
+			add(ProblemTimestamp, "%v", msg)
+		}
+		k := seriesKey(pt)
+		if first, found := points[k]; found {
+			add(ProblemDuplicate, "point already defined at line %v (same series and timestamp)", first)
+		} else {
+			points[k] = line
+		}
+	}
+	rep.Lines = p.Line()
+	sort.SliceStable(rep.Problems, func(i, j int) bool { return rep.Problems[i].Line < rep.Problems[j].Line })
+	return rep, nil
+}
+
+// fieldSeen is the type of a field and the line where it has first been
+// seen
+type fieldSeen struct {
+	t    FieldType
+	line int
+}
+
+func isPlausible(ts int64, unit time.Duration) bool {
+	return ts >= plausibleTimes[0].UnixNano()/int64(unit) && ts <= plausibleTimes[1].UnixNano()/int64(unit)
+}
+
+// toTime converts ts to a time, if it is in the range of time.Time
+// nanosecond timestamps
+func toTime(ts int64, unit time.Duration) string {
+	if ts > 0 && ts > (1<<63-1)/int64(unit) || ts < 0 && ts < (-1<<63)/int64(unit) {
+		return "out of range"
+	}
+	return time.Unix(0, ts*int64(unit)).UTC().Format(time.RFC3339Nano)
+}
+
+func precisionName(unit time.Duration) string {
+	for _, pr := range precisions {
+		if pr.unit == unit {
+			return pr.name
+		}
+	}
+	return unit.String()
+}
+
+// seriesKey returns the key identifying the point: its series (measurement
+// and tags, whatever their order) and its timestamp
+func seriesKey(pt Point) string {
+	tags := make([]string, 0, len(pt.Tags))
+	for _, t := range pt.Tags {
+		tags = append(tags, keyEscaper.Replace(t.Key)+"="+keyEscaper.Replace(t.Value))
+	}
+	sort.Strings(tags)
+	return fmt.Sprintf("%v,%v %v", measurementEscaper.Replace(pt.Measurement), strings.Join(tags, ","), pt.Time)
+}
@@ -0,0 +1,74 @@
+package lineprotocol
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	var tcs = []struct {
+		tcID        string
+		inData      string
+		inUnit      time.Duration
+		expLines    int
+		expPoints   int
+		expProblems []Problem
+	}{
+		{"valid", "# c\nm,a=1 v=1 1439856000000000000\nm,a=2 v=2 1439856000000000000\n", 0, 3, 2, []Problem{}},
+		{"syntax", "m v=1\nm v=\n", 0, 2, 1, []Problem{
+			{Line: 2, Column: 5, Kind: ProblemSyntax, Msg: "missing value of field 'v'"},
+		}},
+		{"escape", `m\x,a=b\c v=1,s="a\nb"`, 0, 1, 1, []Problem{
+			{Line: 1, Column: 2, Kind: ProblemEscape, Msg: "backslash doesn't escape anything, it is kept as is"},
+			{Line: 1, Column: 8, Kind: ProblemEscape, Msg: "backslash doesn't escape anything, it is kept as is"},
+			{Line: 1, Column: 19, Kind: ProblemEscape, Msg: "backslash doesn't escape anything, it is kept as is"},
+		}},
+		{"typeConflict", "m v=1\nn v=1i\nm v=1i\nm v=2\n", 0, 4, 4, []Problem{
+			{Line: 3, Kind: ProblemTypeConflict, Msg: "field 'v' of measurement 'm' is integer, it is float at line 1"},
+		}},
+		{"repeatedField", "m v=1,v=2\n", 0, 1, 1, []Problem{
+			{Line: 1, Kind: ProblemDuplicate, Msg: "field 'v' is repeated"},
+		}},
+		{"duplicatePoint", "m,a=1,b=2 v=1 10\nm,b=2,a=1 v=2 10\nm,a=1 v=1 10\n", time.Second, 3, 3, []Problem{
+			{Line: 1, Kind: ProblemTimestamp, Msg: "timestamp 10 is implausible with precision 's' (1970-01-01T00:00:10Z)"},
+			{Line: 2, Kind: ProblemTimestamp, Msg: "timestamp 10 is implausible with precision 's' (1970-01-01T00:00:10Z)"},
+			{Line: 2, Kind: ProblemDuplicate, Msg: "point already defined at line 1 (same series and timestamp)"},
+			{Line: 3, Kind: ProblemTimestamp, Msg: "timestamp 10 is implausible with precision 's' (1970-01-01T00:00:10Z)"},
+		}},
+		{"precisionMismatch", "m v=1 1439856000\n", 0, 1, 1, []Problem{
+			{Line: 1, Kind: ProblemTimestamp, Msg: "timestamp 1439856000 is implausible with precision 'ns' (1970-01-01T00:00:01.439856Z), precision 's' would be"},
+		}},
+		{"outOfRange", "m v=1 1439856000000000000\n", time.Hour, 1, 1, []Problem{
+			{Line: 1, Kind: ProblemTimestamp, Msg: "timestamp 1439856000000000000 is implausible with precision 'h' (out of range), precision 'ns' would be"},
+		}},
+		{"noTimestamp", "m v=1\nm v=1\n", time.Second, 2, 2, []Problem{}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			rep, err := Validate(strings.NewReader(tc.inData), tc.inUnit)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expLines, rep.Lines)
+			assert.Equal(t, tc.expPoints, rep.Points)
+			assert.Equal(t, tc.expProblems, rep.Problems)
+		})
+	}
+}
+
+func TestValidateSampleData(t *testing.T) {
+	f, err := os.Open("../../testdata/sampleData.txt")
+	assert.Nil(t, err)
+	defer f.Close()
+	rep, err := Validate(f, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, 2000, rep.Points)
+	assert.Empty(t, rep.Problems)
+}
+
+func TestProblemString(t *testing.T) {
+	assert.Equal(t, "3:5: syntax: bad", Problem{Line: 3, Column: 5, Kind: ProblemSyntax, Msg: "bad"}.String())
+	assert.Equal(t, "3: duplicate: bad", Problem{Line: 3, Kind: ProblemDuplicate, Msg: "bad"}.String())
+}
@@ -0,0 +1,135 @@
+package pusher
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RejectedLine is a line that has been rejected by InfluxDB
+type RejectedLine struct {
+	// Line is the line number (starting from 1) in the pushed data, 0 if
+	// InfluxDB didn't provide enough details to find it
+	Line int
+	// Content is the content of the line, if known
+	Content string
+	// Reason is the reason of the rejection, as reported by InfluxDB
+	Reason string
+}
+
+// PartialWriteError is the error returned when InfluxDB rejected some of
+// the points of a batch: the other points of the batch have been written.
+// It wraps the PushError describing the InfluxDB response.
+type PartialWriteError struct {
+	PushError
+	// Rejected lists the lines rejected by InfluxDB
+	Rejected []RejectedLine
+	// Accepted is the number of points of the batch that have been written
+	Accepted int
+	// Dropped is the number of points of the batch that have been dropped
+	Dropped int
+}
+
+func (e *PartialWriteError) Error() string {
+	return fmt.Sprintf("partial write (%v point(s) accepted, %v dropped): %v", e.Accepted, e.Dropped, e.PushError.Error())
+}
+
+// Unwrap returns the PushError describing the InfluxDB response
+func (e *PartialWriteError) Unwrap() error {
+	return e.PushError
+}
+
+// IsPartialWriteError returns true if the error err is a partial write
+// error
+func IsPartialWriteError(err error) bool {
+	var e *PartialWriteError
+	return errors.As(err, &e)
+}
+
+var (
+	droppedRegexp       = regexp.MustCompile(`\s*dropped=(\d+)`)
+	partialPrefixRegexp = regexp.MustCompile(`(?i)^.*partial write:`)
+	lineNumberRegexp    = regexp.MustCompile(`(?i)^line (\d+): (.*)$`)
+	unableToParseRegexp = regexp.MustCompile(`(?i)unable to parse '(.*)': (.*)$`)
+)
+
+// toPartialWriteError turns the error of a write request that InfluxDB
+// rejected with a bad request (1.x) or unprocessable entity (2.x) response
+// into a PartialWriteError, if the response message reports a partial write
+// ("partial write:" prefix or number of dropped points). Otherwise, the
+// whole batch has been rejected and err is returned untouched.
+func toPartialWriteError(err error, bt *batch) error {
+	var pe PushError
+	if !errors.As(err, &pe) {
+		return err
+	}
+	if pe.StatusCode != http.StatusBadRequest && pe.StatusCode != http.StatusUnprocessableEntity {
+		return err
+	}
+	msg := pe.Message
+	if msg == "" {
+		msg = pe.InfluxDBError
+	}
+	dropped := droppedRegexp.FindStringSubmatch(msg)
+	if dropped == nil && !partialPrefixRegexp.MatchString(msg) {
+		return err
+	}
+
+	pwe := PartialWriteError{PushError: pe}
+	unparsed := 0
+	for _, l := range strings.Split(msg, "\n") {
+		l = droppedRegexp.ReplaceAllString(l, "")
+		l = strings.TrimSpace(partialPrefixRegexp.ReplaceAllString(l, ""))
+		if l == "" {
+			continue
+		}
+		rl := RejectedLine{Reason: l}
+		if m := lineNumberRegexp.FindStringSubmatch(l); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			rl.Line = bt.firstLine + n - 1
+			rl.Reason = m[2]
+		}
+		if m := unableToParseRegexp.FindStringSubmatch(rl.Reason); m != nil {
+			rl.Content = m[1]
+			rl.Reason = m[2]
+			if rl.Line == 0 {
+				rl.Line = findLine(bt, rl.Content)
+			}
+			unparsed++
+		}
+		pwe.Rejected = append(pwe.Rejected, rl)
+	}
+
+	// the points that couldn't be parsed aren't counted as dropped (1.x)
+	if dropped != nil {
+		pwe.Dropped, _ = strconv.Atoi(dropped[1])
+	} else {
+		pwe.Dropped = len(pwe.Rejected) - unparsed
+	}
+	pwe.Accepted = bt.points - pwe.Dropped - unparsed
+	if pwe.Accepted < 0 {
+		pwe.Accepted = 0
+	}
+	return &pwe
+}
+
+// findLine returns the line number (in the pushed data) of the line of the
+// batch whose content is content, 0 if it can't be found
+func findLine(bt *batch, content string) int {
+	if bt.file != nil {
+		return 0
+	}
+	s := bufio.NewScanner(bytes.NewReader(bt.data))
+	s.Buffer(nil, len(bt.data)+1)
+	for i := 0; s.Scan(); i++ {
+		if strings.TrimRight(s.Text(), "\r") == content {
+			return bt.firstLine + i
+		}
+	}
+	return 0
+}
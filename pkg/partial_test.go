@@ -0,0 +1,139 @@
+package pusher
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToPartialWriteError(t *testing.T) {
+	bt := &batch{
+		firstLine: 11,
+		data:      []byte("m v=1\nm v=\nm v=3 abc\nm v=4\n"),
+		points:    4,
+	}
+	var tcs = []struct {
+		tcID        string
+		inStatus    int
+		inMsg       string
+		expPartial  bool
+		expAccepted int
+		expDropped  int
+		expRejected []RejectedLine
+	}{
+		{
+			tcID:        "v1UnableToParse",
+			inStatus:    http.StatusBadRequest,
+			inMsg:       "partial write: unable to parse 'm v=': missing field value\nunable to parse 'm v=3 abc': bad timestamp dropped=0",
+			expPartial:  true,
+			expAccepted: 2,
+			expDropped:  0,
+			expRejected: []RejectedLine{
+				{Line: 12, Content: "m v=", Reason: "missing field value"},
+				{Line: 13, Content: "m v=3 abc", Reason: "bad timestamp"},
+			},
+		},
+		{
+			tcID:        "v1UnableToParseWithoutDropped",
+			inStatus:    http.StatusBadRequest,
+			inMsg:       "partial write: unable to parse 'm v=': missing field value",
+			expPartial:  true,
+			expAccepted: 3,
+			expDropped:  0,
+			expRejected: []RejectedLine{
+				{Line: 12, Content: "m v=", Reason: "missing field value"},
+			},
+		},
+		{
+			tcID:        "v1DroppedAndUnableToParse",
+			inStatus:    http.StatusBadRequest,
+			inMsg:       "partial write: unable to parse 'm v=': missing field value dropped=1",
+			expPartial:  true,
+			expAccepted: 2,
+			expDropped:  1,
+			expRejected: []RejectedLine{
+				{Line: 12, Content: "m v=", Reason: "missing field value"},
+			},
+		},
+		{
+			tcID:        "v1FieldTypeConflict",
+			inStatus:    http.StatusBadRequest,
+			inMsg:       `partial write: field type conflict: input field "v" on measurement "m" is type integer, already exists as type float dropped=3`,
+			expPartial:  true,
+			expAccepted: 1,
+			expDropped:  3,
+			expRejected: []RejectedLine{
+				{Reason: `field type conflict: input field "v" on measurement "m" is type integer, already exists as type float`},
+			},
+		},
+		{
+			tcID:        "v2UnprocessableEntity",
+			inStatus:    http.StatusUnprocessableEntity,
+			inMsg:       "failure writing points to database: partial write: points beyond retention policy dropped=2",
+			expPartial:  true,
+			expAccepted: 2,
+			expDropped:  2,
+			expRejected: []RejectedLine{
+				{Reason: "points beyond retention policy"},
+			},
+		},
+		{tcID: "v1UnableToParseNothingWritten", inStatus: http.StatusBadRequest, inMsg: "unable to parse 'm v=': missing field value"},
+		{tcID: "v2WholeBatchRejected", inStatus: http.StatusBadRequest, inMsg: "failed to parse line protocol:\nerrors encountered on line(s):\nline 2: missing field value\nline 3: unable to parse 'm v=3 abc': bad timestamp"},
+		{tcID: "otherBadRequest", inStatus: http.StatusBadRequest, inMsg: "database not found"},
+		{tcID: "emptyMessage", inStatus: http.StatusBadRequest, inMsg: ""},
+		{tcID: "otherStatus", inStatus: http.StatusInternalServerError, inMsg: "unable to parse 'm v=': missing field value"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			inErr := PushError{StatusCode: tc.inStatus, Message: tc.inMsg, errType: errTypeBadRequest, err: fmt.Errorf("e")}
+			err := toPartialWriteError(inErr, bt)
+			assert.Equal(t, tc.expPartial, IsPartialWriteError(err))
+			if !tc.expPartial {
+				assert.Equal(t, inErr, err)
+				return
+			}
+			var pwe *PartialWriteError
+			assert.True(t, errors.As(err, &pwe))
+			assert.Equal(t, tc.expAccepted, pwe.Accepted)
+			assert.Equal(t, tc.expDropped, pwe.Dropped)
+			assert.Equal(t, tc.expRejected, pwe.Rejected)
+			assert.True(t, IsBadRequestError(err))
+			assert.True(t, errors.Is(err, ErrBadRequest))
+		})
+	}
+}
+
+func TestToPartialWriteErrorOtherErrors(t *testing.T) {
+	assert.Nil(t, toPartialWriteError(nil, &batch{}))
+	e := fmt.Errorf("e")
+	assert.Equal(t, e, toPartialWriteError(e, &batch{}))
+}
+
+func TestPushPartialWrite(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		if requests == 2 {
+			rw.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(rw, `{"error":"partial write: field type conflict: input field \"water_level\" on measurement \"h2o_feet\" is type integer, already exists as type float dropped=10"}`)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d", OptWithBatchSize(500))
+	assert.Nil(t, err)
+	rep, err := p.Push("../testdata/sampleData.txt")
+	assert.True(t, IsPartialWriteError(err))
+	assert.Equal(t, 4, requests)
+	assert.Equal(t, 3, rep.Batches)
+	assert.Equal(t, 1990, rep.Points)
+	assert.Equal(t, 10, rep.Dropped)
+	assert.Len(t, rep.Failures, 1)
+	assert.Equal(t, 1, rep.Failures[0].Batch)
+}
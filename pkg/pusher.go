@@ -4,12 +4,20 @@ Package pusher is library that push InfluxDB line protocol file to InfluxDB.
 package pusher
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -65,8 +73,6 @@ var PrecisionToString = map[Precision]string{
 	PrecisionHour:        "h",
 }
 
-var errLogsForDetails = fmt.Errorf("See logs for more details")
-
 // Pusher is a struct modeling the pusher
 type Pusher struct {
 	baseURL         string
@@ -77,33 +83,112 @@ type Pusher struct {
 	precision       string
 	retentionPolicy string
 	timeout         time.Duration
+	org             string
+	bucket          string
+	token           string
+	batchSize       int
+	batchBytes      int
+	concurrency     int
+	retryPolicy     RetryPolicy
+	gzip            bool
+	deadLetter      *deadLetter
+	checkpoints     *checkpointFile
+	resume          bool
+	dryRun          bool
+	queryAuth       bool
+	tlsConfig       *tls.Config
+	roundTripper    http.RoundTripper
+	client          *http.Client
+	converter       Converter
 }
 
 // NewPusher instanciate a new pusher, pushing to db database and using
 // opts configuration functions.
+// If a bucket is specified (OptWithBucket), the pusher targets the
+// InfluxDB 2.x write API and db has to be empty.
 // An error will be returned if anything wrong happens, otherwise no error
 // will be returned.
 func NewPusher(baseURL string, db string, opts ...func(*Pusher) error) (*Pusher, error) {
 	if baseURL == "" {
 		return nil, fmt.Errorf("no url provided")
 	}
-	if db == "" {
-		return nil, fmt.Errorf("no database provided")
+
+	p := Pusher{db: db}
+	for _, opt := range opts {
+		if err := opt(&p); err != nil {
+			return nil, fmt.Errorf("error when creating new pusher: %v", err)
+		}
+	}
+
+	if err := p.checkAPIVersion(); err != nil {
+		return nil, err
+	}
+	if p.resume && p.checkpoints == nil {
+		return nil, fmt.Errorf("resuming requires a checkpoint file")
+	}
+	if err := p.initClient(); err != nil {
+		return nil, err
 	}
 
 	u := baseURL
 	if !strings.HasSuffix(u, "/") {
 		u += "/"
 	}
-	u += "write"
+	if p.isV2() {
+		u += "api/v2/write"
+	} else {
+		u += "write"
+	}
+	p.baseURL = u
 
-	p := Pusher{baseURL: u, db: db}
-	for _, opt := range opts {
-		if err := opt(&p); err != nil {
-			return nil, fmt.Errorf("error when creating new pusher: %v", err)
+	return &p, nil
+}
+
+func (p *Pusher) isV2() bool {
+	return p.bucket != ""
+}
+
+func (p *Pusher) checkAPIVersion() error {
+	if !p.isV2() {
+		if p.db == "" {
+			return fmt.Errorf("no database provided")
+		}
+		if p.org != "" {
+			return fmt.Errorf("organization is only supported with InfluxDB 2.x (bucket required)")
+		}
+		return nil
+	}
+	if p.db != "" {
+		return fmt.Errorf("database and bucket can't be used together")
+	}
+	if p.org == "" {
+		return fmt.Errorf("no organization provided")
+	}
+	if p.consistency != "" {
+		return fmt.Errorf("consistency is not supported with InfluxDB 2.x")
+	}
+	if p.retentionPolicy != "" {
+		return fmt.Errorf("retention policy is not supported with InfluxDB 2.x")
+	}
+	if p.username != "" || p.password != "" {
+		return fmt.Errorf("username and password are not supported with InfluxDB 2.x, use a token")
+	}
+	if p.precision != "" {
+		v, found := v2Precisions[p.precision]
+		if !found {
+			return fmt.Errorf("precision '%v' is not supported with InfluxDB 2.x", p.precision)
 		}
+		p.precision = v
 	}
-	return &p, nil
+	return nil
+}
+
+// v2Precisions maps 1.x precision parameter values to their 2.x equivalent
+var v2Precisions = map[string]string{
+	"ns": "ns",
+	"u":  "us",
+	"ms": "ms",
+	"s":  "s",
 }
 
 // OptWithTimeout is an optional function that specifies timeout
@@ -129,7 +214,8 @@ func OptWithConsistency(c Consistency) func(*Pusher) error {
 }
 
 // OptWithUserPass is an optional function to specify a username and a
-// password to interact with the database
+// password to interact with the database. They are sent through HTTP Basic
+// authentication, unless OptWithQueryParamCredentials is specified.
 func OptWithUserPass(user, pass string) func(*Pusher) error {
 	return func(p *Pusher) error {
 		p.username = user
@@ -138,6 +224,17 @@ func OptWithUserPass(user, pass string) func(*Pusher) error {
 	}
 }
 
+// OptWithQueryParamCredentials is an optional function that makes the
+// username and the password be sent as query parameters (u and p) instead
+// of through HTTP Basic authentication. Credentials may then end up in the
+// logs of proxies.
+func OptWithQueryParamCredentials() func(*Pusher) error {
+	return func(p *Pusher) error {
+		p.queryAuth = true
+		return nil
+	}
+}
+
 // OptWithPrecision is an optional function to specify a precision for the
 // pushed data
 func OptWithPrecision(p Precision) func(*Pusher) error {
@@ -161,6 +258,73 @@ func OptWithRetentionPolicy(rp string) func(*Pusher) error {
 	}
 }
 
+// OptWithOrg is an optional function that specifies the InfluxDB 2.x
+// organization
+func OptWithOrg(org string) func(*Pusher) error {
+	return func(p *Pusher) error {
+		p.org = org
+		return nil
+	}
+}
+
+// OptWithBucket is an optional function that specifies the InfluxDB 2.x
+// bucket. Specifying a bucket switches the pusher to the InfluxDB 2.x write
+// API.
+func OptWithBucket(bucket string) func(*Pusher) error {
+	return func(p *Pusher) error {
+		p.bucket = bucket
+		return nil
+	}
+}
+
+// OptWithToken is an optional function that specifies the token sent in the
+// Authorization header
+func OptWithToken(token string) func(*Pusher) error {
+	return func(p *Pusher) error {
+		p.token = token
+		return nil
+	}
+}
+
+// OptWithBatchSize is an optional function that specifies the maximum number
+// of points sent in a single write request (0 means no limit). If neither
+// the number of points nor the size of the write requests is limited,
+// batches are limited to 10 MiB.
+func OptWithBatchSize(lines int) func(*Pusher) error {
+	return func(p *Pusher) error {
+		if lines < 0 {
+			return fmt.Errorf("negative batch size (%v)", lines)
+		}
+		p.batchSize = lines
+		return nil
+	}
+}
+
+// OptWithBatchBytes is an optional function that specifies the maximum size
+// in bytes of a single write request (0 means no limit, see
+// OptWithBatchSize). A line that is bigger than this size is sent alone.
+func OptWithBatchBytes(n int) func(*Pusher) error {
+	return func(p *Pusher) error {
+		if n < 0 {
+			return fmt.Errorf("negative batch bytes (%v)", n)
+		}
+		p.batchBytes = n
+		return nil
+	}
+}
+
+// OptWithConcurrency is an optional function that specifies how many batches
+// can be sent in parallel (default: 1)
+func OptWithConcurrency(n int) func(*Pusher) error {
+	return func(p *Pusher) error {
+		if n < 1 {
+			return fmt.Errorf("concurrency must be strictly positive (%v)", n)
+		}
+		p.concurrency = n
+		return nil
+	}
+}
+
 type errorType int
 
 const (
@@ -169,6 +333,7 @@ const (
 	errTypeNotFound
 	errTypeServerProblem
 	errTypePusher
+	errTypeRateLimited
 )
 
 var errorTypeToString = map[errorType]string{
@@ -177,19 +342,75 @@ var errorTypeToString = map[errorType]string{
 	errTypeNotFound:      "not found",
 	errTypeServerProblem: "server problem",
 	errTypePusher:        "pusher error",
+	errTypeRateLimited:   "rate limited",
+}
+
+// Sentinel errors matching, through errors.Is, the PushError of the
+// corresponding type
+var (
+	// ErrBadRequest matches InfluxDB bad request errors
+	ErrBadRequest = errors.New("bad request")
+	// ErrUnauthorized matches InfluxDB unauthorized errors
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrNotFound matches InfluxDB not found errors
+	ErrNotFound = errors.New("not found")
+	// ErrServerProblem matches InfluxDB server problem errors
+	ErrServerProblem = errors.New("server problem")
+	// ErrPusher matches pusher errors
+	ErrPusher = errors.New("pusher error")
+	// ErrRateLimited matches InfluxDB rate limiting errors
+	ErrRateLimited = errors.New("rate limited")
+)
+
+var errorTypeToSentinel = map[errorType]error{
+	errTypeBadRequest:    ErrBadRequest,
+	errTypeUnauthorized:  ErrUnauthorized,
+	errTypeNotFound:      ErrNotFound,
+	errTypeServerProblem: ErrServerProblem,
+	errTypePusher:        ErrPusher,
+	errTypeRateLimited:   ErrRateLimited,
 }
 
-type pushError struct {
-	errType errorType
-	err     error
+// PushError is the error returned when a push fails. When the failure comes
+// from an InfluxDB response, it carries the details of this response.
+// PushError supports errors.Is with the ErrXxx sentinel errors and
+// errors.As.
+type PushError struct {
+	// StatusCode is the HTTP status code of the InfluxDB response (0 if the
+	// error doesn't come from a response)
+	StatusCode int
+	// Message is the error message returned by InfluxDB in the response body
+	Message string
+	// InfluxDBError is the value of the X-Influxdb-Error response header
+	InfluxDBError string
+	// InfluxDBVersion is the value of the X-Influxdb-Version response header
+	InfluxDBVersion string
+	// RequestID is the identifier of the request, as returned by InfluxDB
+	RequestID string
+
+	errType    errorType
+	err        error
+	retryable  bool
+	retryAfter time.Duration
 }
 
-func (e pushError) Error() string {
+func (e PushError) Error() string {
 	return fmt.Sprintf("%v: %v", errorTypeToString[e.errType], e.err)
 }
 
+// Unwrap returns the underlying error
+func (e PushError) Unwrap() error {
+	return e.err
+}
+
+// Is returns true if target is the sentinel error of the type of e
+func (e PushError) Is(target error) bool {
+	return errorTypeToSentinel[e.errType] == target
+}
+
 func isErrorType(err error, t errorType) bool {
-	if e, ok := err.(pushError); ok {
+	var e PushError
+	if errors.As(err, &e) {
 		return e.errType == t
 	}
 	return false
@@ -224,8 +445,18 @@ func IsPusherError(err error) bool {
 	return isErrorType(err, errTypePusher)
 }
 
+// IsRateLimitedError returns true if the error err is an InfluxDB rate
+// limiting error (429 response, or 503 response with a Retry-After header)
+func IsRateLimitedError(err error) bool {
+	return isErrorType(err, errTypeRateLimited)
+}
+
 func newError(t errorType, err error) error {
-	return pushError{t, err}
+	return PushError{errType: t, err: err}
+}
+
+func newRetryableError(t errorType, err error) error {
+	return PushError{errType: t, err: err, retryable: true}
 }
 
 func addQueryParamIfNotEmpty(qps *url.Values, k string, v string) {
@@ -234,62 +465,430 @@ func addQueryParamIfNotEmpty(qps *url.Values, k string, v string) {
 	}
 }
 
+// Report summarizes a push
+type Report struct {
+	// Batches is the number of write requests that have been sent
+	// successfully
+	Batches int
+	// Points is the number of points that have been written, including the
+	// points accepted from partially written batches
+	Points int
+	// Dropped is the number of points InfluxDB dropped from partially
+	// written batches
+	Dropped int
+	// DeadLettered is the number of rejected lines that have been written
+	// to the dead letter file
+	DeadLettered int
+	// Failures lists the batches that couldn't be pushed, ordered by batch
+	Failures []BatchFailure
+	// Retries is the number of write requests that have been retried
+	Retries int
+	// Bytes is the size of the data of the batches that have been sent
+	// successfully, before compression
+	Bytes int64
+	// MinTime and MaxTime are the oldest and most recent timestamps of the
+	// points, only computed in dry run mode (zero if unknown)
+	MinTime time.Time
+	MaxTime time.Time
+}
+
+// BatchFailure describes a batch that couldn't be pushed
+type BatchFailure struct {
+	// Batch is the index (starting from 0) of the batch in the pushed data
+	Batch int
+	// Points is the number of points of the batch
+	Points int
+	// Err is the error that occurred when pushing the batch
+	Err error
+}
+
+type batchResult struct {
+	bt       *batch
+	points   int
+	rejected int
+	retries  int
+	err      error
+	minTime  time.Time
+	maxTime  time.Time
+}
+
 // Push pushes data to InfluxDB, an error will be returned if anything
-// wrong happens.
-func (p *Pusher) Push(f string) error {
-	var err error
+// wrong happens. The returned report summarizes what has been sent, even if
+// an error occurred.
+// Batches are sent by a pool of workers (see OptWithConcurrency). As soon as
+// a batch fails, no new batch is sent, the batches that are already being
+// sent are completed and every failure is listed in the report. A batch that
+// has been partially written (PartialWriteError) doesn't stop the push.
+// The returned error is the one of the first failed batch.
+func (p *Pusher) Push(f string) (Report, error) {
+	return p.PushContext(context.Background(), f)
+}
+
+// PushContext pushes the content of the file f to InfluxDB, like Push.
+// The push is aborted as soon as ctx is done.
+func (p *Pusher) PushContext(ctx context.Context, f string) (Report, error) {
+	reader, err := os.Open(f)
+	if err != nil {
+		return Report{}, newError(errTypePusher, fmt.Errorf("error when reading data file '%v': %v", f, err))
+	}
+	defer reader.Close()
+	if p.checkpoints == nil || p.dryRun {
+		return p.push(ctx, f, reader, nil)
+	}
+	pr, err := p.startProgress(f, reader)
+	if err != nil {
+		return Report{}, err
+	}
+	return p.push(ctx, f, reader, pr)
+}
+
+// PushReader pushes the content read from r to InfluxDB, like Push.
+// The push is aborted as soon as ctx is done.
+func (p *Pusher) PushReader(ctx context.Context, r io.Reader) (Report, error) {
+	return p.push(ctx, "", r, nil)
+}
+
+// FileReport is the result of the push of a single file
+type FileReport struct {
+	// File is the pushed file
+	File string
+	// Report summarizes what has been sent
+	Report Report
+	// Err is the error that occurred when pushing the file, nil if the push
+	// succeeded
+	Err error
+}
+
+// PushFiles pushes files to InfluxDB, one after the other. A failing file
+// doesn't prevent the following ones from being pushed, the returned
+// reports tell which files succeeded and which failed. An error is returned
+// if at least one file failed or if ctx is done before every file is pushed.
+func (p *Pusher) PushFiles(ctx context.Context, files []string) ([]FileReport, error) {
+	reps := make([]FileReport, 0, len(files))
+	failed := 0
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return reps, newError(errTypePusher, fmt.Errorf("push aborted: %v", err))
+		}
+		rep, err := p.PushContext(ctx, f)
+		reps = append(reps, FileReport{File: f, Report: rep, Err: err})
+		if err != nil {
+			logrus.Errorf("Error when pushing file '%v': %v", f, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return reps, newError(errTypePusher, fmt.Errorf("%v file(s) out of %v failed", failed, len(files)))
+	}
+	return reps, nil
+}
+
+// push pushes the data read from reader (named name), recording its
+// progress in pr if it isn't nil
+func (p *Pusher) push(ctx context.Context, name string, reader io.Reader, pr *progress) (Report, error) {
+	rep := Report{}
+	uStr, err := p.writeURL()
+	if err != nil {
+		return rep, err
+	}
+	if p.dryRun {
+		logrus.Infof("Dry run, write requests would be sent to %v", redactURL(uStr))
+	} else {
+		logrus.Debugf("URL: %v", redactURL(uStr))
+	}
+
+	what := "data"
+	if name != "" {
+		what = fmt.Sprintf("data file '%v'", name)
+	}
+	var from checkpoint
+	if pr != nil {
+		from = pr.cp
+	}
+	src, closeSrc, err := p.openSource(name, reader, from)
+	if err != nil {
+		return rep, newError(errTypePusher, fmt.Errorf("error when reading %v: %v", what, err))
+	}
+	defer closeSrc()
+
+	jobs := make(chan *batch)
+	results := make(chan batchResult)
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+
+	var readErr error
+	go func() {
+		defer close(jobs)
+		for idx := from.Batches; ; idx++ {
+			bt, err := src.next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				readErr = newError(errTypePusher, fmt.Errorf("error when reading %v: %v", what, err))
+				return
+			}
+			bt.index = idx
+			select {
+			case jobs <- bt:
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for bt := range jobs {
+				select {
+				case <-stop:
+					continue
+				case <-ctx.Done():
+					continue
+				default:
+				}
+				res := batchResult{bt: bt}
+				if p.dryRun {
+					res.minTime, res.maxTime, res.err = p.timeRange(bt)
+					res.points = bt.points
+					if res.err != nil {
+						res.err = newError(errTypePusher, res.err)
+						stopOnce.Do(func() { close(stop) })
+					}
+					results <- res
+					continue
+				}
+				res.retries, res.err = p.sendWithRetries(ctx, p.client, uStr, bt)
+				res.points = bt.points
+				if p.deadLetter != nil && isRejection(res.err) {
+					iso, err := p.isolateRejected(ctx, p.client, uStr, name, bt, res.err)
+					res.points, res.rejected, res.err = iso.accepted, iso.rejected, err
+					res.retries += iso.retries
+				}
+				if res.err != nil && !IsPartialWriteError(res.err) {
+					stopOnce.Do(func() { close(stop) })
+				}
+				results <- res
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var ckptErr error
+	ack := func(bt *batch) {
+		if pr == nil || ckptErr != nil {
+			return
+		}
+		if ckptErr = pr.ack(bt); ckptErr != nil {
+			ckptErr = newError(errTypePusher, ckptErr)
+			logrus.Errorf("Error when saving checkpoint: %v", ckptErr)
+		}
+	}
+	for res := range results {
+		rep.Retries += res.retries
+		if res.err != nil {
+			logrus.Errorf("Batch #%v failed: %v", res.bt.index, res.err)
+			rep.Failures = append(rep.Failures, BatchFailure{Batch: res.bt.index, Points: res.bt.points, Err: res.err})
+			var pwe *PartialWriteError
+			if errors.As(res.err, &pwe) {
+				rep.Points += pwe.Accepted
+				rep.Dropped += pwe.Dropped
+				// the batch has been written, it mustn't be pushed again
+				ack(res.bt)
+			}
+			continue
+		}
+		rep.Batches++
+		rep.Points += res.points
+		rep.DeadLettered += res.rejected
+		rep.Bytes += res.bt.size()
+		if !res.minTime.IsZero() && (rep.MinTime.IsZero() || res.minTime.Before(rep.MinTime)) {
+			rep.MinTime = res.minTime
+		}
+		if res.maxTime.After(rep.MaxTime) {
+			rep.MaxTime = res.maxTime
+		}
+		logrus.Debugf("Batch #%v pushed (%v point(s))", res.bt.index, res.points)
+		ack(res.bt)
+	}
+
+	if len(rep.Failures) > 0 {
+		sort.Slice(rep.Failures, func(i, j int) bool { return rep.Failures[i].Batch < rep.Failures[j].Batch })
+		return rep, rep.Failures[0].Err
+	}
+	if readErr != nil {
+		return rep, readErr
+	}
+	if err := ctx.Err(); err != nil {
+		return rep, newError(errTypePusher, fmt.Errorf("push aborted: %v", err))
+	}
+	if ckptErr != nil {
+		return rep, ckptErr
+	}
+	if pr != nil {
+		if err := pr.done(); err != nil {
+			return rep, newError(errTypePusher, err)
+		}
+	}
+	return rep, nil
+}
+
+func (p *Pusher) workers() int {
+	if p.concurrency < 1 {
+		return 1
+	}
+	return p.concurrency
+}
+
+func (p *Pusher) writeURL() (string, error) {
 	u, err := url.Parse(p.baseURL)
 	if err != nil {
-		return newError(errTypeBadRequest, fmt.Errorf("error when parsing URL '%v': %v", p.baseURL, err))
+		return "", newError(errTypeBadRequest, fmt.Errorf("error when parsing URL '%v': %v", p.baseURL, err))
 	}
 	q := u.Query()
-	addQueryParamIfNotEmpty(&q, "db", p.db)
-	addQueryParamIfNotEmpty(&q, "consistency", p.consistency)
-	addQueryParamIfNotEmpty(&q, "u", p.username)
-	addQueryParamIfNotEmpty(&q, "p", p.password)
+	if p.isV2() {
+		addQueryParamIfNotEmpty(&q, "org", p.org)
+		addQueryParamIfNotEmpty(&q, "bucket", p.bucket)
+	} else {
+		addQueryParamIfNotEmpty(&q, "db", p.db)
+		addQueryParamIfNotEmpty(&q, "consistency", p.consistency)
+		if p.queryAuth {
+			addQueryParamIfNotEmpty(&q, "u", p.username)
+			addQueryParamIfNotEmpty(&q, "p", p.password)
+		}
+		addQueryParamIfNotEmpty(&q, "rp", p.retentionPolicy)
+	}
 	addQueryParamIfNotEmpty(&q, "precision", p.precision)
-	addQueryParamIfNotEmpty(&q, "rp", p.retentionPolicy)
 	u.RawQuery = q.Encode()
-	uStr := u.String()
-	logrus.Debugf("URL: %v", uStr)
+	return u.String(), nil
+}
 
-	reader, err := os.Open(f)
+func (p *Pusher) send(ctx context.Context, client *http.Client, uStr string, bt *batch) error {
+	var body io.Reader = bytes.NewReader(bt.data)
+	var counter *pointCounter
+	if bt.file != nil {
+		body = io.NewSectionReader(bt.file, 0, bt.file.Size())
+		if !bt.counted {
+			counter = newPointCounter(body)
+			body = counter
+		}
+	} else if p.gzip {
+		zr := gzipReader(body)
+		defer zr.Close()
+		body = zr
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uStr, body)
 	if err != nil {
-		return newError(errTypePusher, fmt.Errorf("error when reading data file '%v': %v", f, err))
+		return newError(errTypeBadRequest, fmt.Errorf("error when building request: %v", err))
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if p.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if p.token != "" {
+		req.Header.Set("Authorization", "Token "+p.token)
+	} else if !p.queryAuth && (p.username != "" || p.password != "") {
+		req.SetBasicAuth(p.username, p.password)
 	}
-	defer reader.Close()
 
-	client := http.Client{Timeout: p.timeout}
-	resp, err := client.Post(uStr, "text/plain", reader)
+	resp, err := client.Do(req)
+	if counter != nil {
+		counter.count(bt)
+	}
 	if err != nil {
-		return newError(errTypeBadRequest, fmt.Errorf("error when pushing data: %v", err))
+		if ctx.Err() != nil {
+			return newError(errTypePusher, fmt.Errorf("push aborted: %v", ctx.Err()))
+		}
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			ue.URL = redactURL(ue.URL)
+		}
+		return newRetryableError(errTypeBadRequest, fmt.Errorf("error when pushing data: %v", err))
 	}
 	defer resp.Body.Close()
 
-	return dealWithResponse(resp)
+	return toPartialWriteError(dealWithResponse(resp), bt)
 }
 
 func dealWithResponse(resp *http.Response) error {
-	if resp.StatusCode != http.StatusNoContent {
-		var err error
-		switch resp.StatusCode {
-		case http.StatusBadRequest:
-			err = newError(errTypeBadRequest, errLogsForDetails)
-		case http.StatusInternalServerError:
-			err = newError(errTypeServerProblem, errLogsForDetails)
-		case http.StatusNotFound:
-			err = newError(errTypeNotFound, errLogsForDetails)
-		case http.StatusUnauthorized:
-			err = newError(errTypeUnauthorized, errLogsForDetails)
-		default:
-			err = newError(errTypePusher, fmt.Errorf("unexpected http status code (%v)", resp.StatusCode))
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	c, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return newError(errTypePusher, fmt.Errorf("error while consuming response: %v", err))
+	}
+	logrus.Debugf("Response (%v): %v", resp.StatusCode, string(c))
+
+	e := PushError{
+		StatusCode:      resp.StatusCode,
+		Message:         parseErrorMessage(c),
+		InfluxDBError:   resp.Header.Get("X-Influxdb-Error"),
+		InfluxDBVersion: resp.Header.Get("X-Influxdb-Version"),
+		RequestID:       resp.Header.Get("X-Request-Id"),
+		retryable:       isRetryableStatus(resp.StatusCode),
+	}
+	if e.RequestID == "" {
+		e.RequestID = resp.Header.Get("Request-Id")
+	}
+	switch resp.StatusCode {
+	case http.StatusBadRequest:
+		e.errType = errTypeBadRequest
+	case http.StatusInternalServerError:
+		e.errType = errTypeServerProblem
+	case http.StatusNotFound:
+		e.errType = errTypeNotFound
+	case http.StatusUnauthorized:
+		e.errType = errTypeUnauthorized
+	case http.StatusTooManyRequests:
+		e.errType = errTypeRateLimited
+		e.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	case http.StatusServiceUnavailable:
+		if ra := resp.Header.Get("Retry-After"); ra != "" {
+			e.errType = errTypeRateLimited
+			e.retryAfter = parseRetryAfter(ra, time.Now())
+		} else {
+			e.errType = errTypePusher
+		}
+	default:
+		e.errType = errTypePusher
+	}
+
+	msg := e.Message
+	if msg == "" {
+		msg = e.InfluxDBError
+	}
+	if e.errType == errTypePusher {
+		e.err = fmt.Errorf("unexpected http status code (%v): %v", resp.StatusCode, msg)
+	} else {
+		e.err = fmt.Errorf("http status code %v: %v", resp.StatusCode, msg)
+	}
+	return e
+}
+
+// parseErrorMessage extracts the error message of an InfluxDB response
+// body: the "error" (InfluxDB 1.x) or "message" (InfluxDB 2.x) field of the
+// JSON body, or the trimmed body itself if it is not JSON
+func parseErrorMessage(body []byte) string {
+	var parsed struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		if parsed.Error != "" {
+			return parsed.Error
 		}
-		c, err2 := ioutil.ReadAll(resp.Body)
-		if err2 != nil {
-			return newError(errTypePusher, fmt.Errorf("error while consuming response: %v", err2))
+		if parsed.Message != "" {
+			return parsed.Message
 		}
-		logrus.Errorf("%v", string(c))
-		return err
 	}
-	return nil
+	return strings.TrimSpace(string(body))
 }
@@ -1,10 +1,15 @@
 package pusher
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -78,6 +83,37 @@ func TestNewPusherErrorNoURL(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestNewPusherV2(t *testing.T) {
+	var tcs = []struct {
+		tcID    string
+		inDb    string
+		inOpts  []func(*Pusher) error
+		expErr  bool
+		expURL  string
+		expPrec string
+	}{
+		{"nominal", "", []func(*Pusher) error{OptWithOrg("o"), OptWithBucket("b")}, false, "url/api/v2/write", ""},
+		{"precision", "", []func(*Pusher) error{OptWithOrg("o"), OptWithBucket("b"), OptWithPrecision(PrecisionMicrosecond)}, false, "url/api/v2/write", "us"},
+		{"unsupportedPrecision", "", []func(*Pusher) error{OptWithOrg("o"), OptWithBucket("b"), OptWithPrecision(PrecisionHour)}, true, "", ""},
+		{"noOrg", "", []func(*Pusher) error{OptWithBucket("b")}, true, "", ""},
+		{"orgWithoutBucket", "db", []func(*Pusher) error{OptWithOrg("o")}, true, "", ""},
+		{"dbAndBucket", "db", []func(*Pusher) error{OptWithOrg("o"), OptWithBucket("b")}, true, "", ""},
+		{"consistency", "", []func(*Pusher) error{OptWithOrg("o"), OptWithBucket("b"), OptWithConsistency(ConsistencyAll)}, true, "", ""},
+		{"retentionPolicy", "", []func(*Pusher) error{OptWithOrg("o"), OptWithBucket("b"), OptWithRetentionPolicy("rp")}, true, "", ""},
+		{"userPass", "", []func(*Pusher) error{OptWithOrg("o"), OptWithBucket("b"), OptWithUserPass("u", "p")}, true, "", ""},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			p, err := NewPusher("url", tc.inDb, tc.inOpts...)
+			assert.Equal(t, tc.expErr, err != nil)
+			if !tc.expErr {
+				assert.Equal(t, tc.expURL, p.baseURL)
+				assert.Equal(t, tc.expPrec, p.precision)
+			}
+		})
+	}
+}
+
 func TestOptWithConsistency(t *testing.T) {
 	var tcs = []struct {
 		tcID   string
@@ -128,6 +164,12 @@ func TestOptWithUserPass(t *testing.T) {
 	}
 }
 
+func TestOptWithQueryParamCredentials(t *testing.T) {
+	p := Pusher{}
+	assert.Nil(t, OptWithQueryParamCredentials()(&p))
+	assert.True(t, p.queryAuth)
+}
+
 func TestOptWithTimeout(t *testing.T) {
 	var tcs = []struct {
 		tcID   string
@@ -198,6 +240,16 @@ func TestOptWithRetentionPolicy(t *testing.T) {
 	}
 }
 
+func TestOptWithOrgBucketToken(t *testing.T) {
+	p := Pusher{}
+	assert.Nil(t, OptWithOrg("o")(&p))
+	assert.Nil(t, OptWithBucket("b")(&p))
+	assert.Nil(t, OptWithToken("t")(&p))
+	assert.Equal(t, "o", p.org)
+	assert.Equal(t, "b", p.bucket)
+	assert.Equal(t, "t", p.token)
+}
+
 func TestIsBadRequestError(t *testing.T) {
 	var tcs = []struct {
 		tcID   string
@@ -288,6 +340,24 @@ func TestIsPusherError(t *testing.T) {
 	}
 }
 
+func TestIsRateLimitedError(t *testing.T) {
+	var tcs = []struct {
+		tcID   string
+		inErr  error
+		expRes bool
+	}{
+		{"ok", newError(errTypeRateLimited, fmt.Errorf("e")), true},
+		{"otherPushError", newError(errTypeServerProblem, fmt.Errorf("e")), false},
+		{"otherError", fmt.Errorf("e"), false},
+		{"nil", nil, false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			assert.Equal(t, tc.expRes, IsRateLimitedError(tc.inErr))
+		})
+	}
+}
+
 func TestAddQueryParamIfNotEmpty(t *testing.T) {
 	var tcs = []struct {
 		tcID     string
@@ -311,9 +381,13 @@ func TestPushNominal(t *testing.T) {
 		assert.Equal(t, req.URL.Query().Get("db"), "d")
 		assert.Equal(t, req.URL.Query().Get("consistency"), "all")
 		assert.Equal(t, req.URL.Query().Get("precision"), "h")
-		assert.Equal(t, req.URL.Query().Get("u"), "us")
-		assert.Equal(t, req.URL.Query().Get("p"), "pa")
+		assert.Equal(t, req.URL.Query().Get("u"), "")
+		assert.Equal(t, req.URL.Query().Get("p"), "")
 		assert.Equal(t, req.URL.Query().Get("rp"), "r")
+		user, pass, ok := req.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "us", user)
+		assert.Equal(t, "pa", pass)
 		rw.WriteHeader(http.StatusNoContent)
 	}))
 	defer srv.Close()
@@ -326,7 +400,55 @@ func TestPushNominal(t *testing.T) {
 	)
 	assert.Nil(t, err)
 
-	err = p.Push("../testdata/sampleData.txt")
+	_, err = p.Push("../testdata/sampleData.txt")
+	assert.Nil(t, err)
+}
+
+func TestPushQueryParamCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "us", req.URL.Query().Get("u"))
+		assert.Equal(t, "pa", req.URL.Query().Get("p"))
+		assert.Equal(t, "", req.Header.Get("Authorization"))
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d", OptWithUserPass("us", "pa"), OptWithQueryParamCredentials())
+	assert.Nil(t, err)
+	_, err = p.Push("../testdata/sampleData.txt")
+	assert.Nil(t, err)
+}
+
+func TestPushNetworkErrorRedacted(t *testing.T) {
+	p, err := NewPusher("http://127.0.0.1:1", "d", OptWithUserPass("us", "secret"), OptWithQueryParamCredentials())
+	assert.Nil(t, err)
+	_, err = p.Push("../testdata/sampleData.txt")
+	assert.NotNil(t, err)
+	assert.NotContains(t, err.Error(), "secret")
+	assert.Contains(t, err.Error(), "p=xxxxx")
+}
+
+func TestPushV2Nominal(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/api/v2/write", req.URL.Path)
+		assert.Equal(t, "o", req.URL.Query().Get("org"))
+		assert.Equal(t, "b", req.URL.Query().Get("bucket"))
+		assert.Equal(t, "s", req.URL.Query().Get("precision"))
+		assert.Equal(t, "", req.URL.Query().Get("db"))
+		assert.Equal(t, "Token tok", req.Header.Get("Authorization"))
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "",
+		OptWithOrg("o"),
+		OptWithBucket("b"),
+		OptWithToken("tok"),
+		OptWithPrecision(PrecisionSecond),
+	)
+	assert.Nil(t, err)
+
+	_, err = p.Push("../testdata/sampleData.txt")
 	assert.Nil(t, err)
 }
 
@@ -342,7 +464,7 @@ func TestPushTimeout(t *testing.T) {
 	)
 	assert.Nil(t, err)
 
-	err = p.Push("../testdata/sampleData.txt")
+	_, err = p.Push("../testdata/sampleData.txt")
 	assert.NotNil(t, err)
 }
 
@@ -355,14 +477,14 @@ func TestPushNonExistingFile(t *testing.T) {
 	p, err := NewPusher(srv.URL, "d")
 	assert.Nil(t, err)
 
-	err = p.Push("nonExistingFile.txt")
+	_, err = p.Push("nonExistingFile.txt")
 	assert.True(t, IsPusherError(err))
 }
 
 func TestPushUrlProblem(t *testing.T) {
 	p, err := NewPusher("{", "d")
 	assert.Nil(t, err)
-	err = p.Push("../testdata/sampleData.txt")
+	_, err = p.Push("../testdata/sampleData.txt")
 	assert.True(t, IsBadRequestError(err))
 }
 
@@ -375,29 +497,352 @@ func TestPushWrongStatus(t *testing.T) {
 		expIsNotFound      bool
 		expIsUnauthorized  bool
 		expIsPusher        bool
+		expIsRateLimited   bool
+		inRetryAfter       string
 	}{
 		{tcID: "badRequest", inStatus: http.StatusBadRequest, expIsBadRequest: true},
 		{tcID: "serverProblem", inStatus: http.StatusInternalServerError, expIsServerProblem: true},
 		{tcID: "notFound", inStatus: http.StatusNotFound, expIsNotFound: true},
 		{tcID: "unauthorized", inStatus: http.StatusUnauthorized, expIsUnauthorized: true},
 		{tcID: "pusher", inStatus: http.StatusConflict, expIsPusher: true},
+		{tcID: "tooManyRequests", inStatus: http.StatusTooManyRequests, expIsRateLimited: true},
+		{tcID: "unavailableRetryAfter", inStatus: http.StatusServiceUnavailable, inRetryAfter: "10", expIsRateLimited: true},
+		{tcID: "unavailable", inStatus: http.StatusServiceUnavailable, expIsPusher: true},
 	}
 	for _, tc := range tcs {
 		t.Run(tc.tcID, func(t *testing.T) {
 			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if tc.inRetryAfter != "" {
+					rw.Header().Set("Retry-After", tc.inRetryAfter)
+				}
 				rw.WriteHeader(tc.inStatus)
 			}))
 			defer srv.Close()
 
 			p, err := NewPusher(srv.URL, "d")
 			assert.Nil(t, err)
-			err = p.Push("../testdata/sampleData.txt")
+			_, err = p.Push("../testdata/sampleData.txt")
 
 			assert.Equal(t, tc.expIsBadRequest, IsBadRequestError(err))
 			assert.Equal(t, tc.expIsServerProblem, IsServerProblemError(err))
 			assert.Equal(t, tc.expIsNotFound, IsNotFoundError(err))
 			assert.Equal(t, tc.expIsUnauthorized, IsUnauthorizedError(err))
 			assert.Equal(t, tc.expIsPusher, IsPusherError(err))
+			assert.Equal(t, tc.expIsRateLimited, IsRateLimitedError(err))
+		})
+	}
+}
+
+func TestOptWithBatchSize(t *testing.T) {
+	var tcs = []struct {
+		tcID   string
+		inS    int
+		expErr bool
+	}{
+		{"nominal", 5000, false},
+		{"zero", 0, false},
+		{"negative", -1, true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			p := Pusher{}
+			err := OptWithBatchSize(tc.inS)(&p)
+			assert.Equal(t, tc.expErr, err != nil)
+			if !tc.expErr {
+				assert.Equal(t, tc.inS, p.batchSize)
+			}
+		})
+	}
+}
+
+func TestOptWithBatchBytes(t *testing.T) {
+	var tcs = []struct {
+		tcID   string
+		inB    int
+		expErr bool
+	}{
+		{"nominal", 1024, false},
+		{"zero", 0, false},
+		{"negative", -1, true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			p := Pusher{}
+			err := OptWithBatchBytes(tc.inB)(&p)
+			assert.Equal(t, tc.expErr, err != nil)
+			if !tc.expErr {
+				assert.Equal(t, tc.inB, p.batchBytes)
+			}
+		})
+	}
+}
+
+func TestPushBatches(t *testing.T) {
+	var tcs = []struct {
+		tcID       string
+		inOpts     []func(*Pusher) error
+		expBatches int
+	}{
+		{"noLimit", []func(*Pusher) error{}, 1},
+		{"batchSize", []func(*Pusher) error{OptWithBatchSize(500)}, 4},
+		{"batchBytes", []func(*Pusher) error{OptWithBatchBytes(60000)}, 4},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			requests := 0
+			points := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				c, err := ioutil.ReadAll(req.Body)
+				assert.Nil(t, err)
+				requests++
+				points += strings.Count(string(c), "\n")
+				rw.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			p, err := NewPusher(srv.URL, "d", tc.inOpts...)
+			assert.Nil(t, err)
+			rep, err := p.Push("../testdata/sampleData.txt")
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expBatches, rep.Batches)
+			assert.Equal(t, 2000, rep.Points)
+			assert.Equal(t, tc.expBatches, requests)
+			assert.Equal(t, 2000, points)
+		})
+	}
+}
+
+func TestPushStopsOnFailure(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		if requests == 2 {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d", OptWithBatchSize(500))
+	assert.Nil(t, err)
+	rep, err := p.Push("../testdata/sampleData.txt")
+	assert.True(t, IsBadRequestError(err))
+	assert.Equal(t, 1, rep.Batches)
+	assert.Equal(t, 500, rep.Points)
+	assert.Equal(t, 2, requests)
+}
+
+func TestOptWithConcurrency(t *testing.T) {
+	var tcs = []struct {
+		tcID   string
+		inC    int
+		expErr bool
+	}{
+		{"nominal", 4, false},
+		{"zero", 0, true},
+		{"negative", -1, true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			p := Pusher{}
+			err := OptWithConcurrency(tc.inC)(&p)
+			assert.Equal(t, tc.expErr, err != nil)
+			if !tc.expErr {
+				assert.Equal(t, tc.inC, p.concurrency)
+			}
 		})
 	}
 }
+
+func TestPushConcurrency(t *testing.T) {
+	var inFlight, maxInFlight, requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		cur := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if cur <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, cur) {
+				break
+			}
+		}
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(20 * time.Millisecond)
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d", OptWithBatchSize(100), OptWithConcurrency(4))
+	assert.Nil(t, err)
+	rep, err := p.Push("../testdata/sampleData.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, 20, rep.Batches)
+	assert.Equal(t, 2000, rep.Points)
+	assert.Empty(t, rep.Failures)
+	assert.Equal(t, int32(20), atomic.LoadInt32(&requests))
+	assert.True(t, atomic.LoadInt32(&maxInFlight) > 1)
+	assert.True(t, atomic.LoadInt32(&maxInFlight) <= 4)
+}
+
+func TestPushConcurrencyFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		c, err := ioutil.ReadAll(req.Body)
+		assert.Nil(t, err)
+		time.Sleep(20 * time.Millisecond)
+		if strings.Contains(string(c), "1439856000") || strings.Contains(string(c), "1439892000") {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d", OptWithBatchSize(100), OptWithConcurrency(2))
+	assert.Nil(t, err)
+	rep, err := p.Push("../testdata/sampleData.txt")
+	assert.True(t, IsBadRequestError(err))
+	assert.True(t, len(rep.Failures) >= 1)
+	assert.Equal(t, 0, rep.Failures[0].Batch)
+	assert.Equal(t, 100, rep.Failures[0].Points)
+	assert.True(t, IsBadRequestError(rep.Failures[0].Err))
+	assert.True(t, rep.Batches < 19)
+}
+
+func TestPushReader(t *testing.T) {
+	var received []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		c, err := ioutil.ReadAll(req.Body)
+		assert.Nil(t, err)
+		received = append(received, c...)
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d", OptWithBatchSize(1))
+	assert.Nil(t, err)
+	rep, err := p.PushReader(context.Background(), strings.NewReader("a v=1\nb v=2\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, rep.Batches)
+	assert.Equal(t, 2, rep.Points)
+	assert.Equal(t, "a v=1\nb v=2\n", string(received))
+}
+
+func TestPushContextCancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, err := ioutil.ReadAll(req.Body)
+		assert.Nil(t, err)
+		select {
+		case <-time.After(5 * time.Second):
+		case <-req.Context().Done():
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d", OptWithBatchSize(100))
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	rep, err := p.PushContext(ctx, "../testdata/sampleData.txt")
+	assert.True(t, IsPusherError(err))
+	assert.True(t, time.Since(start) < 2*time.Second)
+	assert.Equal(t, 0, rep.Batches)
+}
+
+func TestPushContextCancelledDuringRetry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d", OptWithRetryPolicy(RetryPolicy{MaxAttempts: 5, InitialBackoff: 5 * time.Second}))
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = p.PushContext(ctx, "../testdata/sampleData.txt")
+	assert.True(t, IsPusherError(err))
+	assert.True(t, time.Since(start) < 2*time.Second)
+}
+
+func TestPushFiles(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d")
+	assert.Nil(t, err)
+	reps, err := p.PushFiles(context.Background(), []string{"../testdata/sampleData.txt", "nonExistingFile.txt", "../testdata/sampleData.txt.gz"})
+	assert.True(t, IsPusherError(err))
+	assert.Len(t, reps, 3)
+	assert.Equal(t, "../testdata/sampleData.txt", reps[0].File)
+	assert.Nil(t, reps[0].Err)
+	assert.Equal(t, 2000, reps[0].Report.Points)
+	assert.Equal(t, "nonExistingFile.txt", reps[1].File)
+	assert.True(t, IsPusherError(reps[1].Err))
+	assert.Equal(t, "../testdata/sampleData.txt.gz", reps[2].File)
+	assert.Nil(t, reps[2].Err)
+	assert.Equal(t, 2000, reps[2].Report.Points)
+
+	reps, err = p.PushFiles(context.Background(), []string{"../testdata/sampleData.txt"})
+	assert.Nil(t, err)
+	assert.Len(t, reps, 1)
+}
+
+func TestParseErrorMessage(t *testing.T) {
+	var tcs = []struct {
+		tcID   string
+		inBody string
+		expMsg string
+	}{
+		{"v1", `{"error":"unable to parse 'a': missing fields"}`, "unable to parse 'a': missing fields"},
+		{"v2", `{"code":"invalid","message":"failed to parse line protocol"}`, "failed to parse line protocol"},
+		{"notJSON", " some text\n", "some text"},
+		{"empty", "", ""},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			assert.Equal(t, tc.expMsg, parseErrorMessage([]byte(tc.inBody)))
+		})
+	}
+}
+
+func TestPushErrorDetails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Influxdb-Error", "unable to parse 'a'")
+		rw.Header().Set("X-Influxdb-Version", "1.7.6")
+		rw.Header().Set("X-Request-Id", "abc-123")
+		rw.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(rw, `{"error":"unable to parse 'a': missing fields"}`)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d")
+	assert.Nil(t, err)
+	_, err = p.Push("../testdata/sampleData.txt")
+
+	var pe PushError
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, http.StatusBadRequest, pe.StatusCode)
+	assert.Equal(t, "unable to parse 'a': missing fields", pe.Message)
+	assert.Equal(t, "unable to parse 'a'", pe.InfluxDBError)
+	assert.Equal(t, "1.7.6", pe.InfluxDBVersion)
+	assert.Equal(t, "abc-123", pe.RequestID)
+	assert.Contains(t, err.Error(), "missing fields")
+	assert.True(t, errors.Is(err, ErrBadRequest))
+	assert.False(t, errors.Is(err, ErrServerProblem))
+}
+
+func TestPushErrorWrapped(t *testing.T) {
+	cause := fmt.Errorf("cause")
+	err := fmt.Errorf("wrapped: %w", newError(errTypeNotFound, cause))
+	assert.True(t, IsNotFoundError(err))
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.True(t, errors.Is(err, cause))
+	assert.False(t, errors.Is(err, ErrPusher))
+	var pe PushError
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, 0, pe.StatusCode)
+}
@@ -0,0 +1,143 @@
+package pusher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RetryPolicy describes how write requests that failed because of a
+// transient problem (network error, 500, 503 or 429 response) are retried.
+// Other failures (bad request, unauthorized, ...) are never retried.
+// When InfluxDB advertises a delay with a Retry-After header, this delay is
+// waited instead of the backoff, whatever MaxBackoff.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts for a batch, including
+	// the first one (0 or 1: no retry)
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, it is doubled
+	// after each attempt
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts (0 means no cap)
+	MaxBackoff time.Duration
+	// Jitter randomizes each delay by +/- Jitter * delay (between 0 and 1)
+	Jitter float64
+	// MaxRetryAfter is the longest delay advertised by InfluxDB that is
+	// waited, the batch failing with a rate limiting error if InfluxDB
+	// advertises a longer one (0 means no limit)
+	MaxRetryAfter time.Duration
+}
+
+// OptWithRetryPolicy is an optional function that specifies how failed
+// write requests are retried
+func OptWithRetryPolicy(rp RetryPolicy) func(*Pusher) error {
+	return func(p *Pusher) error {
+		if rp.MaxAttempts < 0 {
+			return fmt.Errorf("negative max attempts (%v)", rp.MaxAttempts)
+		}
+		if rp.InitialBackoff < 0 || rp.MaxBackoff < 0 {
+			return fmt.Errorf("negative backoff (%v, %v)", rp.InitialBackoff, rp.MaxBackoff)
+		}
+		if rp.MaxRetryAfter < 0 {
+			return fmt.Errorf("negative max retry after (%v)", rp.MaxRetryAfter)
+		}
+		if rp.Jitter < 0 || rp.Jitter > 1 {
+			return fmt.Errorf("jitter must be between 0 and 1 (%v)", rp.Jitter)
+		}
+		p.retryPolicy = rp
+		return nil
+	}
+}
+
+// backoff returns the delay to wait after the attempt-th attempt (starting
+// from 1)
+func (rp RetryPolicy) backoff(attempt int) time.Duration {
+	d := rp.InitialBackoff
+	for i := 1; i < attempt && (rp.MaxBackoff == 0 || d < rp.MaxBackoff); i++ {
+		d *= 2
+	}
+	if rp.Jitter > 0 {
+		d = time.Duration(float64(d) * (1 + rp.Jitter*(2*rand.Float64()-1)))
+	}
+	if rp.MaxBackoff > 0 && d > rp.MaxBackoff {
+		d = rp.MaxBackoff
+	}
+	return d
+}
+
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusTooManyRequests:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter parses a Retry-After header value, that can be either a
+// number of seconds or an HTTP date. 0 is returned if the value can't be
+// parsed.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		if s < 0 {
+			return 0
+		}
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func retryAfter(err error) time.Duration {
+	var e PushError
+	if errors.As(err, &e) {
+		return e.retryAfter
+	}
+	return 0
+}
+
+func isRetryable(err error) bool {
+	var e PushError
+	if errors.As(err, &e) {
+		return e.retryable
+	}
+	return false
+}
+
+// sendWithRetries sends a batch, retrying according to the retry policy.
+// It returns the number of retries.
+func (p *Pusher) sendWithRetries(ctx context.Context, client *http.Client, uStr string, bt *batch) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := p.send(ctx, client, uStr, bt)
+		if err == nil || !isRetryable(err) || attempt >= p.retryPolicy.MaxAttempts {
+			return attempt - 1, err
+		}
+		d := retryAfter(err)
+		if d == 0 {
+			d = p.retryPolicy.backoff(attempt)
+		} else if p.retryPolicy.MaxRetryAfter > 0 && d > p.retryPolicy.MaxRetryAfter {
+			logrus.Warnf("Batch #%v, attempt %v/%v failed (%v), InfluxDB asks to retry in %v, more than %v: giving up", bt.index, attempt, p.retryPolicy.MaxAttempts, err, d, p.retryPolicy.MaxRetryAfter)
+			return attempt - 1, err
+		}
+		logrus.Warnf("Batch #%v, attempt %v/%v failed (%v), retrying in %v", bt.index, attempt, p.retryPolicy.MaxAttempts, err, d)
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return attempt - 1, newError(errTypePusher, fmt.Errorf("push aborted: %v", ctx.Err()))
+		}
+	}
+}
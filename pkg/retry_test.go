@@ -0,0 +1,208 @@
+package pusher
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptWithRetryPolicy(t *testing.T) {
+	var tcs = []struct {
+		tcID   string
+		inRP   RetryPolicy
+		expErr bool
+	}{
+		{"nominal", RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: time.Minute, Jitter: 0.2, MaxRetryAfter: time.Hour}, false},
+		{"empty", RetryPolicy{}, false},
+		{"negativeAttempts", RetryPolicy{MaxAttempts: -1}, true},
+		{"negativeBackoff", RetryPolicy{InitialBackoff: -1}, true},
+		{"negativeMaxBackoff", RetryPolicy{MaxBackoff: -1}, true},
+		{"negativeMaxRetryAfter", RetryPolicy{MaxRetryAfter: -1}, true},
+		{"negativeJitter", RetryPolicy{Jitter: -0.1}, true},
+		{"tooBigJitter", RetryPolicy{Jitter: 1.1}, true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			p := Pusher{}
+			err := OptWithRetryPolicy(tc.inRP)(&p)
+			assert.Equal(t, tc.expErr, err != nil)
+			if !tc.expErr {
+				assert.Equal(t, tc.inRP, p.retryPolicy)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	rp := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	assert.Equal(t, time.Second, rp.backoff(1))
+	assert.Equal(t, 2*time.Second, rp.backoff(2))
+	assert.Equal(t, 4*time.Second, rp.backoff(3))
+	assert.Equal(t, 5*time.Second, rp.backoff(4))
+	assert.Equal(t, 5*time.Second, rp.backoff(100))
+
+	rp = RetryPolicy{InitialBackoff: time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		d := rp.backoff(1)
+		assert.True(t, d >= 500*time.Millisecond && d <= 1500*time.Millisecond)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	var tcs = []struct {
+		tcID   string
+		inErr  error
+		expRes bool
+	}{
+		{"retryable", newRetryableError(errTypeServerProblem, fmt.Errorf("e")), true},
+		{"notRetryable", newError(errTypeServerProblem, fmt.Errorf("e")), false},
+		{"otherError", fmt.Errorf("e"), false},
+		{"nil", nil, false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			assert.Equal(t, tc.expRes, isRetryable(tc.inErr))
+		})
+	}
+}
+
+func TestPushRetries(t *testing.T) {
+	var tcs = []struct {
+		tcID        string
+		inStatus    int
+		inFailures  int32
+		expErr      bool
+		expRequests int32
+		expRetries  int
+	}{
+		{"serverProblem", http.StatusInternalServerError, 2, false, 3, 2},
+		{"unavailable", http.StatusServiceUnavailable, 1, false, 2, 1},
+		{"tooManyRequests", http.StatusTooManyRequests, 1, false, 2, 1},
+		{"tooManyFailures", http.StatusInternalServerError, 5, true, 3, 2},
+		{"badRequest", http.StatusBadRequest, 1, true, 1, 0},
+		{"unauthorized", http.StatusUnauthorized, 1, true, 1, 0},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			var requests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if atomic.AddInt32(&requests, 1) <= tc.inFailures {
+					rw.WriteHeader(tc.inStatus)
+					return
+				}
+				rw.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			p, err := NewPusher(srv.URL, "d", OptWithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+			assert.Nil(t, err)
+			rep, err := p.Push("../testdata/sampleData.txt")
+			assert.Equal(t, tc.expErr, err != nil)
+			assert.Equal(t, tc.expRequests, atomic.LoadInt32(&requests))
+			assert.Equal(t, tc.expRetries, rep.Retries)
+		})
+	}
+}
+
+func TestPushRetriesNetworkError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	srv.Close()
+
+	p, err := NewPusher(srv.URL, "d", OptWithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}))
+	assert.Nil(t, err)
+	rep, err := p.Push("../testdata/sampleData.txt")
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, rep.Retries)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC)
+	var tcs = []struct {
+		tcID string
+		inV  string
+		expD time.Duration
+	}{
+		{"seconds", "120", 2 * time.Minute},
+		{"spaces", " 3 ", 3 * time.Second},
+		{"date", "Wed, 01 May 2019 12:00:30 GMT", 30 * time.Second},
+		{"pastDate", "Wed, 01 May 2019 11:00:00 GMT", 0},
+		{"negative", "-5", 0},
+		{"empty", "", 0},
+		{"garbage", "soon", 0},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			assert.Equal(t, tc.expD, parseRetryAfter(tc.inV, now))
+		})
+	}
+}
+
+func TestPushRetryAfter(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			rw.Header().Set("Retry-After", "1")
+			rw.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d", OptWithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}))
+	assert.Nil(t, err)
+	start := time.Now()
+	rep, err := p.Push("../testdata/sampleData.txt")
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) >= time.Second)
+	assert.Equal(t, 1, rep.Retries)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestPushRetryAfterLongerThanMaxBackoff(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			rw.Header().Set("Retry-After", "1")
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d", OptWithRetryPolicy(RetryPolicy{MaxAttempts: 2, MaxBackoff: time.Millisecond}))
+	assert.Nil(t, err)
+	start := time.Now()
+	rep, err := p.Push("../testdata/sampleData.txt")
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) >= time.Second)
+	assert.Equal(t, 1, rep.Retries)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestPushRetryAfterTooLong(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		rw.Header().Set("Retry-After", "86400")
+		rw.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(srv.URL, "d", OptWithRetryPolicy(RetryPolicy{MaxAttempts: 2, MaxRetryAfter: time.Minute}))
+	assert.Nil(t, err)
+	start := time.Now()
+	rep, err := p.Push("../testdata/sampleData.txt")
+	assert.True(t, IsRateLimitedError(err))
+	assert.True(t, time.Since(start) < 5*time.Second)
+	assert.Equal(t, 0, rep.Retries)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
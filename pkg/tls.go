@@ -0,0 +1,56 @@
+package pusher
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// OptWithTLSConfig is an optional function that specifies the TLS
+// configuration used to talk to an HTTPS InfluxDB (custom certificate
+// authorities, client certificates, ...). See NewTLSConfig.
+func OptWithTLSConfig(c *tls.Config) func(*Pusher) error {
+	return func(p *Pusher) error {
+		if c == nil {
+			return fmt.Errorf("no TLS configuration provided")
+		}
+		p.tlsConfig = c
+		return nil
+	}
+}
+
+// NewTLSConfig builds a TLS configuration trusting the certificate
+// authorities of the PEM file caFile (in addition to the system ones) and
+// presenting the client certificate of the PEM files certFile and keyFile
+// (mutual TLS). Each file is optional, certFile and keyFile have to be
+// provided together. If insecure is true, the certificate of the server
+// isn't verified.
+func NewTLSConfig(caFile string, certFile string, keyFile string, insecure bool) (*tls.Config, error) {
+	c := tls.Config{InsecureSkipVerify: insecure}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("error when reading CA file '%v': %v", caFile, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in CA file '%v'", caFile)
+		}
+		c.RootCAs = pool
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("client certificate and key have to be provided together")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error when loading client certificate '%v': %v", certFile, err)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return &c, nil
+}
@@ -0,0 +1,183 @@
+package pusher
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeServerCA writes the certificate of the TLS test server srv to a PEM
+// file of dir and returns its path
+func writeServerCA(t *testing.T, srv *httptest.Server, dir string) string {
+	f := filepath.Join(dir, "ca.pem")
+	content := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	assert.Nil(t, ioutil.WriteFile(f, content, 0644))
+	return f
+}
+
+// writeClientCert generates a self-signed client certificate, writes it
+// and its key to PEM files of dir and returns their paths and the
+// certificate
+func writeClientCert(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	tmpl := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "pusher"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client.key")
+	assert.Nil(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	assert.Nil(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile, cert
+}
+
+func TestOptWithTLSConfig(t *testing.T) {
+	p := Pusher{}
+	c := &tls.Config{}
+	assert.Nil(t, OptWithTLSConfig(c)(&p))
+	assert.Equal(t, c, p.tlsConfig)
+	assert.NotNil(t, OptWithTLSConfig(nil)(&p))
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile, _ := writeClientCert(t, dir)
+	notPem := filepath.Join(dir, "notPem.txt")
+	assert.Nil(t, ioutil.WriteFile(notPem, []byte("a"), 0644))
+
+	var tcs = []struct {
+		tcID       string
+		inCA       string
+		inCert     string
+		inKey      string
+		inInsecure bool
+		expErr     bool
+	}{
+		{"empty", "", "", "", false, false},
+		{"insecure", "", "", "", true, false},
+		{"ca", certFile, "", "", false, false},
+		{"clientCert", "", certFile, keyFile, false, false},
+		{"missingCA", filepath.Join(dir, "missing.pem"), "", "", false, true},
+		{"noCertInCA", notPem, "", "", false, true},
+		{"certWithoutKey", "", certFile, "", false, true},
+		{"keyWithoutCert", "", "", keyFile, false, true},
+		{"invalidKey", "", certFile, notPem, false, true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			c, err := NewTLSConfig(tc.inCA, tc.inCert, tc.inKey, tc.inInsecure)
+			assert.Equal(t, tc.expErr, err != nil)
+			if !tc.expErr {
+				assert.Equal(t, tc.inInsecure, c.InsecureSkipVerify)
+				assert.Equal(t, tc.inCA != "", c.RootCAs != nil)
+				assert.Equal(t, tc.inCert != "", len(c.Certificates) == 1)
+			}
+		})
+	}
+}
+
+func TestPushTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	ca := writeServerCA(t, srv, dir)
+
+	var tcs = []struct {
+		tcID       string
+		inCA       string
+		inInsecure bool
+		expErr     bool
+	}{
+		{"unknownAuthority", "", false, true},
+		{"ca", ca, false, false},
+		{"insecure", "", true, false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			c, err := NewTLSConfig(tc.inCA, "", "", tc.inInsecure)
+			assert.Nil(t, err)
+			p, err := NewPusher(srv.URL, "d", OptWithTLSConfig(c))
+			assert.Nil(t, err)
+			rep, err := p.Push("../testdata/sampleData.txt")
+			assert.Equal(t, tc.expErr, err != nil)
+			if !tc.expErr {
+				assert.Equal(t, 2000, rep.Points)
+			}
+		})
+	}
+}
+
+func TestPushMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pusher")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile, cert := writeClientCert(t, dir)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if assert.Len(t, req.TLS.PeerCertificates, 1) {
+			assert.Equal(t, "pusher", req.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+	ca := writeServerCA(t, srv, dir)
+
+	var tcs = []struct {
+		tcID   string
+		inCert string
+		inKey  string
+		expErr bool
+	}{
+		{"noClientCert", "", "", true},
+		{"clientCert", certFile, keyFile, false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			c, err := NewTLSConfig(ca, tc.inCert, tc.inKey, false)
+			assert.Nil(t, err)
+			p, err := NewPusher(srv.URL, "d", OptWithTLSConfig(c))
+			assert.Nil(t, err)
+			_, err = p.Push("../testdata/sampleData.txt")
+			assert.Equal(t, tc.expErr, err != nil)
+		})
+	}
+}